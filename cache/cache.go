@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// EnvCacheDir overrides the directory cached data is stored in
+const EnvCacheDir = "ELASTIQ_CACHE_DIR"
+
+type entry struct {
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+func Dir() (string, error) {
+	if dir := os.Getenv(EnvCacheDir); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache dir: %w", err)
+	}
+
+	return path.Join(dir, "elastiq"), nil
+}
+
+func filePath(namespace, key string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return path.Join(dir, namespace, hex.EncodeToString(sum[:])), nil
+}
+
+// Load reads not expired value stored by key into v, returns false if there is no such value
+func Load(namespace, key string, v interface{}) (bool, error) {
+	p, err := filePath(namespace, key)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read cache file='%s': %w", p, err)
+	}
+
+	e := entry{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		// broken cache is the same as no cache
+		return false, nil
+	}
+
+	if time.Now().After(e.Expires) {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(e.Value, v); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Store saves v by key for ttl, files are readable only by the user as they may contain credentials
+func Store(namespace, key string, v interface{}, ttl time.Duration) error {
+	p, err := filePath(namespace, key)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached value: %w", err)
+	}
+
+	data, err := json.Marshal(entry{Expires: time.Now().Add(ttl), Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(path.Dir(p), 0700); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	if err := ioutil.WriteFile(p, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache file='%s': %w", p, err)
+	}
+
+	return nil
+}
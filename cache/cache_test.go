@@ -0,0 +1,37 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"elastiq/cache"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv(cache.EnvCacheDir, dir)
+	defer os.Unsetenv(cache.EnvCacheDir)
+
+	v := ""
+	found, err := cache.Load("test", "missing", &v)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, cache.Store("test", "key", "value", time.Minute))
+	found, err = cache.Load("test", "key", &v)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", v)
+
+	require.NoError(t, cache.Store("test", "expired", "value", -time.Minute))
+	found, err = cache.Load("test", "expired", &v)
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -3,10 +3,146 @@ package client
 import (
 	"context"
 	"elastiq/config"
+	"elastiq/output"
 	"elastiq/query"
 	"io"
+	"time"
 )
 
 type Client interface {
 	Query(ctx context.Context, env *config.Env, q *query.Query, o query.Options) (io.Reader, error)
+	Get(ctx context.Context, env *config.Env, id string, q *query.Query, o query.Options) (io.Reader, error)
+}
+
+// Record is a found record prepared for output along with values of every key it is ordered by
+type Record struct {
+	Fields  map[string]interface{}
+	OrderBy []interface{}
+
+	// Timestamp, Message and Service are taken from common fields configured for the env,
+	// so records of different sources can be shown as one timeline
+	Timestamp time.Time
+	Message   interface{}
+	Service   interface{}
+
+	// ID and Source (only with KeepSource option) identify and hold the record as it is found
+	ID     string
+	Source map[string]interface{}
+}
+
+// NewRecord makes a record of source record found in env,
+// its fields have to be set after the source record is prepared for output
+func NewRecord(e *config.Env, source map[string]interface{}, orders []*query.Order) *Record {
+	rf := e.GetRecordFields()
+	r := &Record{}
+
+	ts, _ := output.Lookup(source, rf.Timestamp)
+	var parsed bool
+	r.Timestamp, parsed = output.ParseTimestamp(ts)
+	r.Message, _ = output.Lookup(source, rf.Message)
+	r.Service, _ = output.Lookup(source, rf.Service)
+
+	for _, order := range orders {
+		v, _ := output.Lookup(source, order.By)
+		r.OrderBy = append(r.OrderBy, v)
+	}
+
+	// records are ordered by timestamp by default, timestamps which failed to parse are compared as they are
+	if len(orders) == 0 {
+		switch {
+		case parsed:
+			r.OrderBy = []interface{}{r.Timestamp.UTC().Format(time.RFC3339Nano)}
+		case ts != nil:
+			r.OrderBy = []interface{}{ts}
+		}
+	}
+
+	return r
+}
+
+// Unified returns the record in the shape common for all sources,
+// fields of the record are put to attributes
+func (r *Record) Unified() map[string]interface{} {
+	var ts interface{}
+	if !r.Timestamp.IsZero() {
+		ts = r.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+
+	return map[string]interface{}{
+		"timestamp":  ts,
+		"message":    r.Message,
+		"service":    r.Service,
+		"attributes": r.Fields,
+	}
+}
+
+// SearchClient is implemented by sources able to return found records instead of formatted output,
+// so results of several envs can be merged
+type SearchClient interface {
+	Search(ctx context.Context, env *config.Env, q *query.Query, o query.Options) ([]*Record, error)
+}
+
+// ContextClient is implemented by sources able to fetch records surrounding an anchor record
+type ContextClient interface {
+	Context(ctx context.Context, env *config.Env, a *query.Anchor, q *query.Query, o query.Options) (io.Reader, error)
+}
+
+type IndexInfo struct {
+	Name    string
+	Type    string
+	Health  string
+	Status  string
+	Docs    int64
+	Size    int64
+	Created string
+	Indices []string
+}
+
+// IndicesClient is implemented by sources able to list indices, aliases and data streams
+type IndicesClient interface {
+	Indices(ctx context.Context, env *config.Env, pattern string) ([]*IndexInfo, error)
+}
+
+type FieldInfo struct {
+	Name         string
+	Types        []string
+	Searchable   bool
+	Aggregatable bool
+	// Indices lists indices per type when the field is mapped differently across indices
+	Indices map[string][]string
+}
+
+func (fi *FieldInfo) HasConflicts() bool {
+	return len(fi.Types) > 1
+}
+
+// FieldsClient is implemented by sources able to describe mapped fields
+type FieldsClient interface {
+	Fields(ctx context.Context, env *config.Env, index string) ([]*FieldInfo, error)
+}
+
+// SliceState is the progress of an exported slice, it is saved to checkpoint to resume export
+type SliceState struct {
+	// After is the sort value of the last exported record,
+	// IDs are ids of exported records having this value, so they are not exported twice on resume
+	After interface{} `json:"after,omitempty"`
+	IDs   []string    `json:"ids,omitempty"`
+	// Missing is set once records without the sort value are exported, they are sorted after all others
+	Missing  bool  `json:"missing,omitempty"`
+	Exported int64 `json:"exported"`
+	Total    int64 `json:"total"`
+	Done     bool  `json:"done"`
+}
+
+// Batch is a page of exported records of a slice along with the state of the slice after it
+type Batch struct {
+	Slice   int
+	Records []map[string]interface{}
+	State   SliceState
+}
+
+// ExportClient is implemented by sources able to export records by concurrent slices,
+// every slice of state which is not done is exported (or resumed) by its own worker
+type ExportClient interface {
+	Export(ctx context.Context, env *config.Env, q *query.Query, o query.Options, slices []*SliceState, batches chan<- *Batch) error
 }
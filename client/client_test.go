@@ -0,0 +1,61 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    map[string]interface{}
+		orders    []*query.Order
+		timestamp time.Time
+		orderBy   []interface{}
+	}{
+		{
+			name:      "ordered by timestamp",
+			source:    map[string]interface{}{"@timestamp": "2021-07-14T12:00:00+02:00", "message": "hi"},
+			timestamp: time.Date(2021, 7, 14, 10, 0, 0, 0, time.UTC),
+			orderBy:   []interface{}{"2021-07-14T10:00:00Z"},
+		},
+		{
+			name:      "epoch millis",
+			source:    map[string]interface{}{"@timestamp": "1626256800000"},
+			timestamp: time.Date(2021, 7, 14, 10, 0, 0, 0, time.UTC),
+			orderBy:   []interface{}{"2021-07-14T10:00:00Z"},
+		},
+		{
+			name:    "timestamp failed to parse is ordered as it is",
+			source:  map[string]interface{}{"@timestamp": "14.07.2021 10:00:00"},
+			orderBy: []interface{}{"14.07.2021 10:00:00"},
+		},
+		{
+			name:   "without timestamp",
+			source: map[string]interface{}{"message": "hi"},
+		},
+		{
+			name:      "ordered by keys",
+			source:    map[string]interface{}{"@timestamp": "2021-07-14T10:00:00Z", "level": "error"},
+			orders:    []*query.Order{{By: "level"}, {By: "code"}},
+			timestamp: time.Date(2021, 7, 14, 10, 0, 0, 0, time.UTC),
+			orderBy:   []interface{}{"error", nil},
+		},
+	}
+
+	e := &config.Env{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := client.NewRecord(e, tt.source, tt.orders)
+			require.True(t, tt.timestamp.Equal(r.Timestamp), r.Timestamp)
+			require.Equal(t, tt.orderBy, r.OrderBy)
+			require.Equal(t, tt.source["message"], r.Message)
+		})
+	}
+}
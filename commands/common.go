@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"elastiq/client"
+	"elastiq/config"
+	q "elastiq/query"
+	"elastiq/source/datadog"
+	"elastiq/source/elasticsearch"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type outputFlags struct {
+	ascurl    bool
+	raw       bool
+	recursive string
+}
+
+func addOutputFlags(cmd *cobra.Command) *outputFlags {
+	of := outputFlags{}
+
+	pflags := cmd.PersistentFlags()
+	pflags.BoolVarP(&of.ascurl, "curl", "", false, "output elasticsearch request as curl")
+	pflags.BoolVarP(&of.raw, "raw", "r", false, "toggle raw ouput from elasticsearch (disables output post processing)")
+	pflags.StringVarP(&of.recursive, "recursive", "R", "", "toggle recursive decoding")
+
+	return &of
+}
+
+func (of *outputFlags) options(cmd *cobra.Command, cf *commonFlags) q.Options {
+	options := q.Options{
+		Debug:     cf.debug,
+		FromStdin: cf.stdin,
+		Raw:       of.raw,
+		AsCurl:    of.ascurl,
+		Recursive: nil,
+	}
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name == "recursive" {
+			rlist := strings.Split(of.recursive, ",")
+			options.Recursive = &rlist
+		}
+	})
+
+	return options
+}
+
+func getClient(cfg *config.Config, e *config.Env) (client.Client, error) {
+	switch e.Source {
+	case config.SourceElasticSearch:
+		return elasticsearch.NewClient(cfg), nil
+	case config.SourceDataDog:
+		return datadog.NewClient(cfg), nil
+	}
+
+	return nil, fmt.Errorf("unknown source='%s'", e.Source)
+}
+
+// getSavedQuery returns saved query specified as @name argument rendered with params,
+// empty query is returned if no saved query is specified
+func getSavedQuery(cfg *config.Config, args, params []string) (*config.SavedQuery, error) {
+	if len(args) == 0 {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("--param can be used only with saved query")
+		}
+
+		return &config.SavedQuery{}, nil
+	}
+
+	if !strings.HasPrefix(args[0], "@") {
+		return nil, fmt.Errorf("unexpected argument='%s', saved query has to be specified as @name", args[0])
+	}
+
+	saved, err := cfg.GetQuery(strings.TrimPrefix(args[0], "@"))
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string][]string{}
+	for _, v := range params {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("failed to parse param='%s', expected key=value", v)
+		}
+
+		values[kv[0]] = append(values[kv[0]], kv[1])
+	}
+
+	saved, err = saved.Render(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render query='%s': %w", args[0], err)
+	}
+
+	return saved, nil
+}
+
+// getTimeFilter makes filter of time range like a/b, end of range defaults to now
+func getTimeFilter(timeRange string) (string, error) {
+	t := strings.Split(timeRange, "/")
+	if len(t) > 2 {
+		return "", fmt.Errorf("to many delimiters in timerange='%s'", timeRange)
+	}
+
+	if len(t) == 1 {
+		t = append(t, "now")
+	}
+
+	return fmt.Sprintf("@timestamp intime '%s' '%s'", t[0], t[1]), nil
+}
+
+// getOrder parses comma separated orders resolving aliases of their keys
+func getOrder(order string, aliases map[string]string) ([]*q.Order, error) {
+	orders, err := q.GetOrders(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse order: %w", err)
+	}
+
+	for _, o := range orders {
+		if alias, ok := aliases[o.By]; ok {
+			o.By = alias
+		}
+	}
+
+	return orders, nil
+}
+
+// parseFilters parses filters resolving aliases of keys and values configured for the env
+func parseFilters(strs []string, tfs q.TimeFilterSettings, cfg *config.Config, e *config.Env) ([]*q.Filter, error) {
+	aliases := cfg.GetAliases(e)
+
+	filters := []*q.Filter{}
+	for _, v := range strs {
+		filter, err := q.ParseFilter(v, tfs, aliases)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse filter='%s': %w", v, err)
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return q.ExpandValueAliases(filters, cfg.GetValueAliases(e)), nil
+}
@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"elastiq/config"
+	"elastiq/output"
+
+	"github.com/spf13/cobra"
+)
+
+const starterConfig = `# elastiq config, see https://github.com/koshoi/elastiq for all settings
+
+# other config files to merge, relative paths are resolved against this file directory
+# include = ["~/.config/elastiq/team.toml"]
+
+# settings shared by envs extending it
+[env.base]
+index    = "logs-*"
+output   = "pretty"
+timezone = "UTC"
+# limit       = 10
+# order       = "@timestamp/desc"
+# time_format = "2006-01-02T15:04:05Z07:00"
+
+# [env.base.authorization.basic]
+# user     = "username"
+# password = "${env:ES_PASS}"     # or "file:~/.secrets/es" or "keyring:elastiq/prod"
+
+[env.dev]
+extends   = "base"
+endpoints = ["http://localhost:9200"]
+default   = true
+
+# [env.dd]
+# endpoints       = ["https://api.datadoghq.eu"]
+# source          = "datadog"
+# dd_api_key      = "${env:DD_API_KEY}"
+# dd_personal_key = "${env:DD_APP_KEY}"
+
+[output.pretty]
+format             = "json"
+exclude            = ["kubernetes"]
+decode_recursively = true
+
+[output.message]
+format = "json"
+only   = ["message"]
+
+[aliases]
+app = "kubernetes.labels.app"
+`
+
+func relativeFiles(files []string) string {
+	wd, _ := os.Getwd()
+	result := make([]string, 0, len(files))
+	for _, f := range files {
+		if rel, err := filepath.Rel(wd, f); err == nil && !strings.HasPrefix(rel, "..") {
+			f = rel
+		}
+		result = append(result, f)
+	}
+
+	return strings.Join(result, ", ")
+}
+
+func getConfigValidateCommand(cf *commonFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "check config reporting every problem found (secrets are not resolved)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadConfig(config.ConfigPaths(cf.config)...)
+			if err != nil {
+				return err
+			}
+
+			problems := cfg.Check()
+			for _, p := range problems {
+				fmt.Fprintln(os.Stderr, p)
+			}
+
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problem(s) in config", len(problems))
+			}
+
+			fmt.Println("config is valid")
+			return nil
+		},
+	}
+}
+
+func getConfigShowCommand(cf *commonFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "print effective config with includes and project configs merged and secrets masked",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.ReadConfig(cf.config)
+			if err != nil {
+				return err
+			}
+
+			return cfg.WriteMasked(os.Stdout)
+		},
+	}
+}
+
+func getConfigEnvsCommand(cf *commonFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "envs",
+		Short: "list envs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.ReadConfig(cf.config)
+			if err != nil {
+				return err
+			}
+
+			records := make([]map[string]interface{}, 0, len(cfg.Envs))
+			for k, v := range cfg.Envs {
+				r := map[string]interface{}{
+					"name":    k,
+					"source":  string(v.Source),
+					"default": v.IsDefault,
+					"files":   relativeFiles(v.Files),
+				}
+
+				if v.Index != "" {
+					r["index"] = v.Index
+				}
+
+				if v.Extends != "" {
+					r["extends"] = v.Extends
+				}
+
+				records = append(records, r)
+			}
+
+			sort.Slice(records, func(i, j int) bool {
+				return records[i]["name"].(string) < records[j]["name"].(string)
+			})
+
+			result, err := output.TableOutput(records, []string{"name", "source", "default", "index", "extends", "files"})
+			if err != nil {
+				return err
+			}
+
+			io.Copy(os.Stdout, result)
+			return nil
+		},
+	}
+}
+
+func getConfigOutputsCommand(cf *commonFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "outputs",
+		Short: "list outputs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.ReadConfig(cf.config)
+			if err != nil {
+				return err
+			}
+
+			records := make([]map[string]interface{}, 0, len(cfg.Outputs))
+			for k, v := range cfg.Outputs {
+				r := map[string]interface{}{
+					"name":    k,
+					"format":  v.Format,
+					"default": v.IsDefault,
+					"files":   relativeFiles(v.Files),
+				}
+
+				if v.Extends != "" {
+					r["extends"] = v.Extends
+				}
+
+				records = append(records, r)
+			}
+
+			sort.Slice(records, func(i, j int) bool {
+				return records[i]["name"].(string) < records[j]["name"].(string)
+			})
+
+			result, err := output.TableOutput(records, []string{"name", "format", "default", "extends", "files"})
+			if err != nil {
+				return err
+			}
+
+			io.Copy(os.Stdout, result)
+			return nil
+		},
+	}
+}
+
+func getConfigInitCommand(cf *commonFlags) *cobra.Command {
+	force := false
+
+	cmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "write a commented starter config (to the config path by default)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := cf.config
+			if len(args) > 0 {
+				p = args[0]
+			}
+
+			if _, err := os.Stat(p); err == nil && !force {
+				return fmt.Errorf("config file='%s' already exists, use --force to overwrite it", p)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+				return fmt.Errorf("failed to create config dir: %w", err)
+			}
+
+			if err := ioutil.WriteFile(p, []byte(starterConfig), 0600); err != nil {
+				return fmt.Errorf("failed to write config file='%s': %w", p, err)
+			}
+
+			fmt.Printf("config written to '%s'\n", p)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "overwrite existing config")
+
+	return cmd
+}
+
+func getConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "inspect and create config",
+	}
+
+	cf := addCommonFlags(cmd)
+
+	cmd.AddCommand(getConfigValidateCommand(cf))
+	cmd.AddCommand(getConfigShowCommand(cf))
+	cmd.AddCommand(getConfigEnvsCommand(cf))
+	cmd.AddCommand(getConfigOutputsCommand(cf))
+	cmd.AddCommand(getConfigInitCommand(cf))
+
+	return cmd
+}
+
+func AddConfigCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getConfigCommand())
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"elastiq/client"
+	"elastiq/config"
+	q "elastiq/query"
+	"elastiq/timetools"
+
+	"github.com/spf13/cobra"
+)
+
+func getContextCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "context [id]",
+		Short: "show records around the given one (specified by id or time)",
+		Args:  cobra.MaximumNArgs(1),
+	}
+
+	cf := addCommonFlags(cmd)
+	of := addOutputFlags(cmd)
+
+	strs := []string{}
+	same := []string{}
+	at := ""
+	size := 0
+
+	pflags := cmd.PersistentFlags()
+	pflags.StringArrayVarP(&strs, "filter", "f", []string{}, "filter values like key=value")
+	pflags.StringArrayVarP(&same, "same", "s", []string{}, "fields to have the same value as in anchor record (e.g. kubernetes.pod.name)")
+	pflags.StringVarP(&at, "at", "", "", "use time as anchor instead of a record id")
+	pflags.IntVarP(&size, "lines", "n", 20, "number of records to output before and after anchor")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == (at == "") {
+			return fmt.Errorf("either record id or --at has to be specified")
+		}
+
+		cfg, err := config.ReadConfig(cf.config)
+		if err != nil {
+			return err
+		}
+
+		e, err := cfg.GetEnv(cf.env)
+		if err != nil {
+			return err
+		}
+
+		tz, err := e.GetTimezone(cf.tz)
+		if err != nil {
+			return err
+		}
+
+		timeSettings := q.TimeFilterSettings{
+			TimeZone:   tz,
+			TimeFormat: e.GetTimeFormat(cf.tf),
+		}
+
+		c, err := getClient(cfg, e)
+		if err != nil {
+			return err
+		}
+
+		cc, ok := c.(client.ContextClient)
+		if !ok {
+			return fmt.Errorf("source='%s' does not support context command", e.Source)
+		}
+
+		options := of.options(cmd, cf)
+		if options.Raw || options.AsCurl {
+			return fmt.Errorf("--raw and --curl are not supported by context command")
+		}
+
+		anchor := &q.Anchor{Size: size}
+		if len(args) > 0 {
+			anchor.ID = args[0]
+		} else {
+			t, err := timetools.ParseDate(at, time.Now().In(tz))
+			if err != nil {
+				return fmt.Errorf("failed to parse anchor time='%s': %w", at, err)
+			}
+
+			anchor.Time = &t
+		}
+
+		aliases := cfg.GetAliases(e)
+		for _, v := range same {
+			if alias, ok := aliases[v]; ok {
+				v = alias
+			}
+
+			anchor.Same = append(anchor.Same, v)
+		}
+
+		query := &q.Query{
+			Filters: []*q.Filter{},
+			Index:   cf.index,
+			Output:  cf.output,
+		}
+
+		if e.Order != "" {
+			query.Order, err = getOrder(e.Order, aliases)
+			if err != nil {
+				return err
+			}
+		}
+
+		query.Filters, err = parseFilters(strs, timeSettings, cfg, e)
+		if err != nil {
+			return err
+		}
+
+		result, err := cc.Context(cmd.Context(), e, anchor, query, options)
+		if err != nil {
+			return fmt.Errorf("failed to get context: %w", err)
+		}
+
+		io.Copy(os.Stdout, result)
+		return nil
+	}
+
+	return cmd
+}
+
+func AddContextCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getContextCommand())
+}
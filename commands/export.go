@@ -0,0 +1,372 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"reflect"
+	"time"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/output"
+	q "elastiq/query"
+
+	"github.com/spf13/cobra"
+)
+
+// exportSpec describes an export, checkpoint can be used to resume only the same export
+type exportSpec struct {
+	Env     string   `json:"env"`
+	Index   string   `json:"index"`
+	Filters []string `json:"filters"`
+	Output  string   `json:"output"`
+	Slices  int      `json:"slices"`
+}
+
+// exportCheckpoint is saved while exporting, offset is the size of file written for the slices state,
+// relative times of filters (e.g. -24h) are resolved against the time the export started at,
+// so resumed export covers the same time window
+type exportCheckpoint struct {
+	Spec    exportSpec           `json:"spec"`
+	Started time.Time            `json:"started"`
+	Offset  int64                `json:"offset"`
+	Slices  []*client.SliceState `json:"slices"`
+}
+
+func readCheckpoint(path string) (*exportCheckpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	// sort values are kept as they are, since long values (e.g. of date_nanos) don't fit float64
+	cp := exportCheckpoint{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint='%s': %w", path, err)
+	}
+
+	return &cp, nil
+}
+
+// save writes checkpoint to a temporary file first, so interruption never leaves it half written
+func (cp *exportCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (cp *exportCheckpoint) progress() (exported, total int64, done int) {
+	for _, s := range cp.Slices {
+		exported += s.Exported
+		total += s.Total
+		if s.Done {
+			done++
+		}
+	}
+
+	return exported, total, done
+}
+
+func (cp *exportCheckpoint) printProgress() {
+	exported, total, done := cp.progress()
+	fmt.Fprintf(os.Stderr, "\rexported %d of %d records, %d of %d slices done", exported, total, done, len(cp.Slices))
+}
+
+func getExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [@saved-query]",
+		Short: "export all records matching filters to a file by concurrent slices",
+		Args:  cobra.MaximumNArgs(1),
+	}
+
+	cf := addCommonFlags(cmd)
+	of := addOutputFlags(cmd)
+
+	strs := []string{}
+	timeRange := ""
+	params := []string{}
+	file := ""
+	checkpoint := ""
+	slices := 0
+	batch := 0
+	restart := false
+
+	pflags := cmd.PersistentFlags()
+	pflags.StringArrayVarP(&strs, "filter", "f", []string{}, "filter values like key=value")
+	pflags.StringVarP(&timeRange, "time", "t", "", "specify time filter as a/b")
+	pflags.StringArrayVarP(&params, "param", "", []string{}, "set parameter of saved query like key=value")
+	pflags.StringVarP(&file, "file", "w", "", "file to write records to")
+	pflags.StringVarP(&checkpoint, "checkpoint", "", "", "checkpoint file to resume export from (defaults to file with .checkpoint suffix)")
+	pflags.IntVarP(&slices, "workers", "n", 4, "number of slices exported concurrently")
+	pflags.IntVarP(&batch, "batch", "b", 1000, "number of records requested at once by every worker")
+	pflags.BoolVarP(&restart, "restart", "", false, "ignore checkpoint and export from the beginning")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if file == "" {
+			return fmt.Errorf("file to export to has to be specified with --file")
+		}
+
+		if slices < 1 || batch < 1 {
+			return fmt.Errorf("--workers and --batch have to be positive")
+		}
+
+		if of.raw || of.ascurl || cf.stdin {
+			return fmt.Errorf("--raw, --curl and --stdin are not supported by export command")
+		}
+
+		if checkpoint == "" {
+			checkpoint = file + ".checkpoint"
+		}
+
+		cfg, err := config.ReadConfig(cf.config)
+		if err != nil {
+			return err
+		}
+
+		saved, err := getSavedQuery(cfg, args, params)
+		if err != nil {
+			return err
+		}
+
+		e, err := cfg.GetEnv(cf.env)
+		if err != nil {
+			return err
+		}
+
+		c, err := getClient(cfg, e)
+		if err != nil {
+			return err
+		}
+
+		ec, ok := c.(client.ExportClient)
+		if !ok {
+			return fmt.Errorf("source='%s' does not support export command", e.Source)
+		}
+
+		filters := append(append([]string{}, saved.Filters...), strs...)
+		if timeRange == "" {
+			timeRange = saved.Time
+		}
+
+		if timeRange != "" {
+			tf, err := getTimeFilter(timeRange)
+			if err != nil {
+				return err
+			}
+
+			filters = append(filters, tf)
+		}
+
+		tz, err := e.GetTimezone(cf.tz)
+		if err != nil {
+			return err
+		}
+
+		timeSettings := q.TimeFilterSettings{
+			TimeZone:   tz,
+			TimeFormat: e.GetTimeFormat(cf.tf),
+		}
+
+		query := &q.Query{
+			Index:  cf.index,
+			Output: cf.output,
+			Limit:  batch,
+		}
+
+		if query.Index == "" {
+			query.Index = saved.Index
+		}
+
+		if query.Output == "" {
+			query.Output = saved.Output
+		}
+
+		o, err := cfg.GetOutput(e, query.Output)
+		if err != nil {
+			return fmt.Errorf("failed to get output: %w", err)
+		}
+
+		spec := exportSpec{Env: cf.env, Index: query.Index, Filters: filters, Output: query.Output, Slices: slices}
+		cp := &exportCheckpoint{Spec: spec, Started: time.Now()}
+		if !restart {
+			prev, err := readCheckpoint(checkpoint)
+			if err != nil {
+				return err
+			}
+
+			if prev != nil && !reflect.DeepEqual(prev.Spec, spec) {
+				return fmt.Errorf("checkpoint='%s' was saved for another export, use --restart to ignore it", checkpoint)
+			}
+
+			if prev != nil {
+				cp = prev
+			}
+
+			// checkpoints saved before the start time was kept resolve relative times against now
+			if cp.Started.IsZero() {
+				cp.Started = time.Now()
+			}
+		}
+
+		timeSettings.Now = &cp.Started
+		query.Filters, err = parseFilters(filters, timeSettings, cfg, e)
+		if err != nil {
+			return err
+		}
+
+		flags := os.O_CREATE | os.O_WRONLY
+		if cp.Slices == nil {
+			flags |= os.O_TRUNC
+			for i := 0; i < slices; i++ {
+				cp.Slices = append(cp.Slices, &client.SliceState{})
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "resuming export from checkpoint='%s'\n", checkpoint)
+		}
+
+		f, err := os.OpenFile(file, flags, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+
+		// records written after the last checkpoint are exported again
+		if err := f.Truncate(cp.Offset); err != nil {
+			return fmt.Errorf("failed to truncate file to checkpoint: %w", err)
+		}
+
+		if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek file to checkpoint: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		go func() {
+			select {
+			case <-interrupt:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		// memory is bounded by a batch per worker waiting to be written
+		batches := make(chan *client.Batch, slices)
+		exportErr := make(chan error, 1)
+		go func() {
+			exportErr <- ec.Export(ctx, e, query, of.options(cmd, cf), cp.Slices, batches)
+			close(batches)
+		}()
+
+		w := bufio.NewWriter(f)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		// checkpoint is saved only for records synced to file
+		flush := func() error {
+			if err := w.Flush(); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+
+			if err := f.Sync(); err != nil {
+				return fmt.Errorf("failed to sync file: %w", err)
+			}
+
+			return cp.save(checkpoint)
+		}
+
+		var writeErr error
+		for b := range batches {
+			if writeErr != nil {
+				continue
+			}
+
+			r, err := output.FormatOutput(b.Records, o)
+			if err != nil {
+				writeErr = err
+				cancel()
+				continue
+			}
+
+			n, err := io.Copy(w, r)
+			if err != nil {
+				writeErr = fmt.Errorf("failed to write file: %w", err)
+				cancel()
+				continue
+			}
+
+			cp.Offset += n
+			state := b.State
+			cp.Slices[b.Slice] = &state
+
+			select {
+			case <-ticker.C:
+				if err := flush(); err != nil {
+					writeErr = err
+					cancel()
+					continue
+				}
+				cp.printProgress()
+			default:
+			}
+		}
+
+		err = <-exportErr
+		if writeErr == nil {
+			writeErr = flush()
+		}
+		cp.printProgress()
+		fmt.Fprintln(os.Stderr)
+
+		if writeErr != nil {
+			return writeErr
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("export interrupted, run the same command to resume from checkpoint='%s'", checkpoint)
+			}
+
+			return fmt.Errorf("export failed, run the same command to resume from checkpoint='%s': %w", checkpoint, err)
+		}
+
+		if err := os.Remove(checkpoint); err != nil {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+func AddExportCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getExportCommand())
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"elastiq/client"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.checkpoint")
+
+	cp, err := readCheckpoint(path)
+	require.NoError(t, err)
+	require.Nil(t, cp)
+
+	started := time.Date(2021, 7, 14, 10, 0, 0, 0, time.UTC)
+	saved := &exportCheckpoint{
+		Spec:    exportSpec{Env: "dev", Index: "logs", Filters: []string{"@timestamp intime '-24h' 'now'"}, Slices: 2},
+		Started: started,
+		Offset:  120,
+		Slices: []*client.SliceState{
+			// nanoseconds don't fit float64
+			{After: json.Number("1626256800000000001"), IDs: []string{"a"}, Exported: 3, Total: 5},
+			{After: json.Number("1626256800000"), Missing: true, Exported: 2, Total: 2, Done: true},
+		},
+	}
+	require.NoError(t, saved.save(path))
+
+	cp, err = readCheckpoint(path)
+	require.NoError(t, err)
+	require.Equal(t, saved.Spec, cp.Spec)
+	require.True(t, started.Equal(cp.Started))
+	require.Equal(t, saved.Offset, cp.Offset)
+	require.Equal(t, saved.Slices, cp.Slices)
+
+	_, err = ioutil.ReadFile(path + ".tmp")
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	_, err = readCheckpoint(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/output"
+	q "elastiq/query"
+
+	"github.com/spf13/cobra"
+)
+
+// EnvField is added to every record found by a query run against several envs
+const EnvField = "_env"
+
+type buildQuery func(e *config.Env, warn func(string)) (client.Client, *q.Query, error)
+
+// lessRecords compares records by values of every order key in turn,
+// missing values go last unless order places them first
+func lessRecords(a, b *client.Record, orders []*q.Order) bool {
+	for i, o := range orders {
+		var va, vb interface{}
+		if i < len(a.OrderBy) {
+			va = a.OrderBy[i]
+		}
+
+		if i < len(b.OrderBy) {
+			vb = b.OrderBy[i]
+		}
+
+		if va == nil || vb == nil {
+			if (va == nil) == (vb == nil) {
+				continue
+			}
+
+			return (va == nil) == (o.Missing == "_first")
+		}
+
+		switch c := output.Compare(va, vb); {
+		case c == 0:
+			continue
+		case o.Ascending:
+			return c < 0
+		default:
+			return c > 0
+		}
+	}
+
+	return false
+}
+
+type envResult struct {
+	env     *config.Env
+	query   *q.Query
+	records []*client.Record
+	err     error
+}
+
+// checkMergeable returns error if queries built for envs differ in limit, order or output,
+// which records of all envs are merged and shown by
+func checkMergeable(names []string, results []*envResult) error {
+	var base *envResult
+	baseName := ""
+	for i, r := range results {
+		if r.query == nil {
+			continue
+		}
+
+		if base == nil {
+			base, baseName = r, names[i]
+			continue
+		}
+
+		switch {
+		case r.query.Limit != base.query.Limit:
+			return fmt.Errorf("envs='%s' and '%s' have different limits %d and %d, specify --limit to query them together", baseName, names[i], base.query.Limit, r.query.Limit)
+		case !reflect.DeepEqual(r.query.Order, base.query.Order):
+			return fmt.Errorf("envs='%s' and '%s' have different orders, specify --orderby to query them together", baseName, names[i])
+		case outputName(r) != outputName(base):
+			return fmt.Errorf("envs='%s' and '%s' have different outputs '%s' and '%s', specify --output to query them together", baseName, names[i], outputName(base), outputName(r))
+		}
+	}
+
+	return nil
+}
+
+// outputName returns name of the output the query is shown by, empty name is the default output
+func outputName(r *envResult) string {
+	if r.query.Output != "" {
+		return r.query.Output
+	}
+
+	return r.env.Output
+}
+
+// fanOut runs the query against every env matching cf.env concurrently
+// and merges found records by the order of the query (by timestamp if order is not specified).
+// Records of envs with different sources are shown in the unified shape.
+// Failed envs are reported to stderr, error is returned only if all of them failed
+func fanOut(cmd *cobra.Command, cfg *config.Config, cf *commonFlags, of *outputFlags, build buildQuery) error {
+	if of.raw || of.ascurl || cf.stdin {
+		return fmt.Errorf("--raw, --curl and --stdin can not be used with several envs")
+	}
+
+	names, err := cfg.MatchEnvs(cf.env)
+	if err != nil {
+		return err
+	}
+
+	options := of.options(cmd, cf)
+	results := make([]*envResult, len(names))
+	wg := sync.WaitGroup{}
+
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			r := &envResult{}
+			results[i] = r
+
+			r.env, r.err = cfg.GetEnv(name)
+			if r.err != nil {
+				return
+			}
+
+			var c client.Client
+			c, r.query, r.err = build(r.env, func(w string) {
+				fmt.Fprintf(os.Stderr, "warning: env='%s': %s\n", name, w)
+			})
+			if r.err != nil {
+				return
+			}
+
+			sc, ok := c.(client.SearchClient)
+			if !ok {
+				r.err = fmt.Errorf("source='%s' does not support querying several envs", r.env.Source)
+				return
+			}
+
+			r.records, r.err = sc.Search(cmd.Context(), r.env, r.query, options)
+			if r.err != nil {
+				r.err = fmt.Errorf("failed to run query: %w", r.err)
+			}
+		}(i, name)
+	}
+
+	wg.Wait()
+
+	if err := checkMergeable(names, results); err != nil {
+		return err
+	}
+
+	var first *envResult
+	sources := map[config.Source]bool{}
+	records := []*client.Record{}
+	envs := map[*client.Record]string{}
+	for i, r := range results {
+		if r.env != nil {
+			sources[r.env.Source] = true
+		}
+
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "env='%s': %s\n", names[i], r.err)
+			continue
+		}
+
+		if first == nil {
+			first = r
+		}
+
+		for _, rec := range r.records {
+			envs[rec] = names[i]
+		}
+		records = append(records, r.records...)
+	}
+
+	if first == nil {
+		return fmt.Errorf("query failed in all of %d envs", len(names))
+	}
+
+	orders := first.query.Order
+	if len(orders) == 0 {
+		// records are ordered by timestamp by default
+		orders = []*q.Order{{Ascending: false}}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return lessRecords(records[i], records[j], orders)
+	})
+
+	if len(records) > first.query.Limit {
+		records = records[:first.query.Limit]
+	}
+
+	o, err := cfg.GetOutput(first.env, first.query.Output)
+	if err != nil {
+		return fmt.Errorf("failed to get output: %w", err)
+	}
+
+	// records of different sources have different fields,
+	// so they are shown in the shape common for all sources
+	unified := len(sources) > 1
+
+	fields := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		f := r.Fields
+		if unified {
+			f = r.Unified()
+		}
+
+		f[EnvField] = envs[r]
+		fields = append(fields, f)
+	}
+
+	result, err := output.FormatOutput(fields, o)
+	if err != nil {
+		return err
+	}
+
+	io.Copy(cmd.OutOrStdout(), result)
+	return nil
+}
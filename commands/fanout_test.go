@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"elastiq/client"
+	"elastiq/config"
+	q "elastiq/query"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// fanOutServers returns elasticsearch and datadog servers replying with the same records for every search
+func fanOutServers(t *testing.T, hits []map[string]interface{}, events []map[string]interface{}) (*httptest.Server, *httptest.Server) {
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasSuffix(r.URL.Path, "/_search"), r.URL.Path)
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"hits": map[string]interface{}{"hits": hits, "total": map[string]int{"value": len(hits)}},
+		}))
+	}))
+
+	dd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v2/logs/events/search", r.URL.Path)
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"data": events}))
+	}))
+
+	return es, dd
+}
+
+// fanOutConfig returns config with envs es, es-eu (elasticsearch) and dd (datadog) along with extra settings
+func fanOutConfig(t *testing.T, es, dd *httptest.Server, extra ...string) *config.Config {
+	cfg := config.Config{}
+	_, err := toml.Decode(fmt.Sprintf(`
+[env.es]
+endpoints = ["%s"]
+index     = "logs"
+output    = "json"
+
+[env.es-eu]
+endpoints = ["%s"]
+index     = "logs-eu"
+output    = "json"
+
+[env.dd]
+endpoints       = ["%s"]
+source          = "datadog"
+dd_api_key      = "api"
+dd_personal_key = "app"
+output          = "json"
+
+[output.json]
+format = "json"
+
+[output.short]
+format = "json"
+only   = ["message"]
+`, es.URL, es.URL, dd.URL)+strings.Join(extra, "\n"), &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	return &cfg
+}
+
+// runFanOut runs query against envs and returns printed records
+func runFanOut(t *testing.T, cfg *config.Config, envs string, build buildQuery) ([]map[string]interface{}, error) {
+	out := &bytes.Buffer{}
+	var err error
+	cmd := &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err = fanOut(cmd, cfg, &commonFlags{env: envs}, &outputFlags{}, build)
+			return nil
+		},
+	}
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{})
+	require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+	records := []map[string]interface{}{}
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		r := map[string]interface{}{}
+		require.NoError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+
+	return records, err
+}
+
+func buildWith(cfg *config.Config, query func(e *config.Env) *q.Query) buildQuery {
+	return func(e *config.Env, warn func(string)) (client.Client, *q.Query, error) {
+		c, err := getClient(cfg, e)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return c, query(e), nil
+	}
+}
+
+func TestFanOutMixedSources(t *testing.T) {
+	es, dd := fanOutServers(t,
+		[]map[string]interface{}{
+			{"_id": "es-1", "_source": map[string]interface{}{"@timestamp": "2021-07-14T10:00:01Z", "message": "es 1", "service": "api", "code": 200}},
+			{"_id": "es-2", "_source": map[string]interface{}{"@timestamp": "2021-07-14T10:00:03Z", "message": "es 2", "service": "api", "code": 500}},
+		},
+		[]map[string]interface{}{
+			{"id": "dd-1", "attributes": map[string]interface{}{
+				"timestamp":  "2021-07-14T10:00:02Z",
+				"message":    "dd 1",
+				"service":    "worker",
+				"attributes": map[string]interface{}{"service": "shadowed", "code": "201"},
+			}},
+		},
+	)
+	defer es.Close()
+	defer dd.Close()
+
+	cfg := fanOutConfig(t, es, dd)
+	records, err := runFanOut(t, cfg, "es,dd", buildWith(cfg, func(e *config.Env) *q.Query {
+		return &q.Query{Limit: 10, Output: "json"}
+	}))
+	require.NoError(t, err)
+
+	// records of both sources are merged by timestamp in the unified shape
+	require.Equal(t, []map[string]interface{}{
+		{
+			"timestamp":  "2021-07-14T10:00:03Z",
+			"message":    "es 2",
+			"service":    "api",
+			"attributes": map[string]interface{}{"@timestamp": "2021-07-14T10:00:03Z", "message": "es 2", "service": "api", "code": "500"},
+			EnvField:     "es",
+		},
+		{
+			"timestamp":  "2021-07-14T10:00:02Z",
+			"message":    "dd 1",
+			"service":    "worker",
+			"attributes": map[string]interface{}{"service": "shadowed", "code": "201"},
+			EnvField:     "dd",
+		},
+		{
+			"timestamp":  "2021-07-14T10:00:01Z",
+			"message":    "es 1",
+			"service":    "api",
+			"attributes": map[string]interface{}{"@timestamp": "2021-07-14T10:00:01Z", "message": "es 1", "service": "api", "code": "200"},
+			EnvField:     "es",
+		},
+	}, records)
+}
+
+func TestFanOutMergeSettings(t *testing.T) {
+	es, dd := fanOutServers(t,
+		[]map[string]interface{}{
+			{"_id": "es-1", "_source": map[string]interface{}{"@timestamp": "2021-07-14T10:00:01Z", "message": "es 1"}},
+			{"_id": "es-2", "_source": map[string]interface{}{"@timestamp": "2021-07-14T10:00:03Z", "message": "es 2"}},
+		},
+		nil,
+	)
+	defer es.Close()
+	defer dd.Close()
+
+	asc := []*q.Order{{By: "@timestamp", Ascending: true}}
+
+	tests := []struct {
+		name string
+		// eu changes query of es-eu env
+		eu       func(query *q.Query)
+		err      string
+		messages []interface{}
+		envs     []interface{}
+	}{
+		{
+			name:     "same settings",
+			eu:       func(query *q.Query) {},
+			messages: []interface{}{"es 1", "es 1", "es 2"},
+			envs:     []interface{}{"es", "es-eu", "es"},
+		},
+		{
+			name: "different limits",
+			eu:   func(query *q.Query) { query.Limit = 5 },
+			err:  "envs='es' and 'es-eu' have different limits 3 and 5, specify --limit to query them together",
+		},
+		{
+			name: "different orders",
+			eu:   func(query *q.Query) { query.Order = nil },
+			err:  "envs='es' and 'es-eu' have different orders, specify --orderby to query them together",
+		},
+		{
+			name: "different outputs",
+			eu:   func(query *q.Query) { query.Output = "short" },
+			err:  "envs='es' and 'es-eu' have different outputs 'json' and 'short', specify --output to query them together",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := fanOutConfig(t, es, dd)
+			records, err := runFanOut(t, cfg, "es,es-eu", buildWith(cfg, func(e *config.Env) *q.Query {
+				query := &q.Query{Limit: 3, Order: asc}
+				if e.Index == "logs-eu" {
+					tt.eu(query)
+				}
+
+				return query
+			}))
+
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			messages, envs := []interface{}{}, []interface{}{}
+			for _, r := range records {
+				messages = append(messages, r["message"])
+				envs = append(envs, r[EnvField])
+			}
+			require.Equal(t, tt.messages, messages)
+			require.Equal(t, tt.envs, envs)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"elastiq/cache"
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/output"
+	q "elastiq/query"
+
+	"github.com/spf13/cobra"
+)
+
+var fieldsColumns = []string{"name", "type", "searchable", "aggregatable", "conflicts", "aliases"}
+
+const fieldCapsTTL = time.Hour
+
+// getMapping returns field types of the index, field capabilities are cached on disk as they rarely change
+func getMapping(ctx context.Context, c client.Client, e *config.Env, index string) (q.Mapping, error) {
+	fc, ok := c.(client.FieldsClient)
+	if !ok {
+		return nil, fmt.Errorf("source='%s' does not support filters validation", e.Source)
+	}
+
+	if index == "" {
+		index = e.Index
+	}
+
+	key := strings.Join(e.Endpoints, ",") + "/" + index
+	mapping := q.Mapping{}
+	if found, _ := cache.Load("field_caps", key, &mapping); found {
+		return mapping, nil
+	}
+
+	fields, err := fc.Fields(ctx, e, index)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range fields {
+		mapping[v.Name] = v.Types
+	}
+
+	if err := cache.Store("field_caps", key, mapping, fieldCapsTTL); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+	}
+
+	return mapping, nil
+}
+
+// inFieldPath tells whether field is the path itself or one of its subfields
+func inFieldPath(field, path string) bool {
+	return path == "" || field == path || strings.HasPrefix(field, path+".")
+}
+
+func getFieldsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fields [path]",
+		Short: "list mapped fields of env index with their types",
+		Args:  cobra.MaximumNArgs(1),
+	}
+
+	cf := addCommonFlags(cmd)
+
+	format := ""
+
+	pflags := cmd.PersistentFlags()
+	pflags.StringVarP(&format, "format", "F", "table", "output format (table or json)")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.ReadConfig(cf.config)
+		if err != nil {
+			return err
+		}
+
+		e, err := cfg.GetEnv(cf.env)
+		if err != nil {
+			return err
+		}
+
+		c, err := getClient(cfg, e)
+		if err != nil {
+			return err
+		}
+
+		fc, ok := c.(client.FieldsClient)
+		if !ok {
+			return fmt.Errorf("source='%s' does not support fields command", e.Source)
+		}
+
+		configured := cfg.GetAliases(e)
+
+		path := ""
+		if len(args) > 0 {
+			path = args[0]
+			if alias, ok := configured[path]; ok {
+				path = alias
+			}
+		}
+
+		fields, err := fc.Fields(cmd.Context(), e, cf.index)
+		if err != nil {
+			return err
+		}
+
+		aliases := map[string][]string{}
+		for k, v := range configured {
+			aliases[v] = append(aliases[v], k)
+		}
+
+		records := []map[string]interface{}{}
+		for _, v := range fields {
+			if !inFieldPath(v.Name, path) {
+				continue
+			}
+
+			r := map[string]interface{}{
+				"name":         v.Name,
+				"type":         strings.Join(v.Types, ","),
+				"searchable":   v.Searchable,
+				"aggregatable": v.Aggregatable,
+				"conflicts":    v.HasConflicts(),
+			}
+
+			if a := aliases[v.Name]; len(a) > 0 {
+				sort.Strings(a)
+				r["aliases"] = strings.Join(a, ",")
+			}
+
+			if format == "json" {
+				r["type"] = v.Types
+				if a := aliases[v.Name]; len(a) > 0 {
+					r["aliases"] = a
+				}
+				if v.HasConflicts() {
+					r["indices"] = v.Indices
+				}
+			}
+
+			records = append(records, r)
+		}
+
+		var result io.Reader
+		switch format {
+		case "json":
+			result, err = output.JSONOutput(records)
+		case "table":
+			result, err = output.TableOutput(records, fieldsColumns)
+		default:
+			return fmt.Errorf("format='%s' is not implemented", format)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		io.Copy(os.Stdout, result)
+		return nil
+	}
+
+	return cmd
+}
+
+func AddFieldsCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getFieldsCommand())
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFieldPath(t *testing.T) {
+	tests := []struct {
+		field string
+		path  string
+		in    bool
+	}{
+		{field: "kubernetes.labels.app", path: "", in: true},
+		{field: "kubernetes.labels", path: "kubernetes.labels", in: true},
+		{field: "kubernetes.labels.app", path: "kubernetes.labels", in: true},
+		{field: "kubernetes.labels.app", path: "kubernetes.label", in: false},
+		{field: "kubernetes.labelset", path: "kubernetes.labels", in: false},
+		{field: "kubernetes", path: "kubernetes.labels", in: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field+"/"+tt.path, func(t *testing.T) {
+			require.Equal(t, tt.in, inFieldPath(tt.field, tt.path))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"elastiq/config"
+	q "elastiq/query"
+
+	"github.com/spf13/cobra"
+)
+
+func getGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <id>",
+		Short: "get a single document by its id",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	cf := addCommonFlags(cmd)
+	of := addOutputFlags(cmd)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.ReadConfig(cf.config)
+		if err != nil {
+			return err
+		}
+
+		e, err := cfg.GetEnv(cf.env)
+		if err != nil {
+			return err
+		}
+
+		client, err := getClient(cfg, e)
+		if err != nil {
+			return err
+		}
+
+		query := &q.Query{
+			Index:  cf.index,
+			Output: cf.output,
+		}
+
+		result, err := client.Get(cmd.Context(), e, args[0], query, of.options(cmd, cf))
+		if err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
+
+		io.Copy(os.Stdout, result)
+		return nil
+	}
+
+	return cmd
+}
+
+func AddGetCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getGetCommand())
+}
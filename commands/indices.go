@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/output"
+	q "elastiq/query"
+
+	"github.com/spf13/cobra"
+)
+
+var indicesColumns = []string{"name", "type", "health", "status", "docs", "size", "created"}
+
+func humanSize(size int64) string {
+	units := []string{"b", "kb", "mb", "gb", "tb", "pb"}
+	v := float64(size)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d%s", size, units[i])
+	}
+
+	return fmt.Sprintf("%.1f%s", v, units[i])
+}
+
+func lessIndices(a, b *client.IndexInfo, by string) (bool, error) {
+	switch by {
+	case "name":
+		return a.Name < b.Name, nil
+	case "type":
+		return a.Type < b.Type, nil
+	case "health":
+		return a.Health < b.Health, nil
+	case "status":
+		return a.Status < b.Status, nil
+	case "docs":
+		return a.Docs < b.Docs, nil
+	case "size":
+		return a.Size < b.Size, nil
+	case "created":
+		return a.Created < b.Created, nil
+	}
+
+	return false, fmt.Errorf("can not sort by '%s', allowed keys are [%s]", by, strings.Join(indicesColumns, ", "))
+}
+
+func getIndicesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "indices [pattern]",
+		Short: "list indices, aliases and data streams matching pattern (defaults to env index)",
+		Args:  cobra.MaximumNArgs(1),
+	}
+
+	cf := addCommonFlags(cmd)
+
+	format := ""
+	orderBy := ""
+
+	pflags := cmd.PersistentFlags()
+	pflags.StringVarP(&format, "format", "F", "table", "output format (table or json)")
+	pflags.StringVarP(&orderBy, "sort", "s", "name/asc", "sort by column like size/desc")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.ReadConfig(cf.config)
+		if err != nil {
+			return err
+		}
+
+		e, err := cfg.GetEnv(cf.env)
+		if err != nil {
+			return err
+		}
+
+		c, err := getClient(cfg, e)
+		if err != nil {
+			return err
+		}
+
+		ic, ok := c.(client.IndicesClient)
+		if !ok {
+			return fmt.Errorf("source='%s' does not support indices command", e.Source)
+		}
+
+		order, err := q.GetOrder(orderBy)
+		if err != nil {
+			return fmt.Errorf("failed to parse sort: %w", err)
+		}
+
+		if _, err := lessIndices(&client.IndexInfo{}, &client.IndexInfo{}, order.By); err != nil {
+			return err
+		}
+
+		pattern := cf.index
+		if len(args) > 0 {
+			pattern = args[0]
+		}
+
+		indices, err := ic.Indices(cmd.Context(), e, pattern)
+		if err != nil {
+			return err
+		}
+
+		sort.SliceStable(indices, func(i, j int) bool {
+			a, b := indices[i], indices[j]
+			if !order.Ascending {
+				a, b = b, a
+			}
+
+			less, _ := lessIndices(a, b, order.By)
+			return less
+		})
+
+		records := make([]map[string]interface{}, 0, len(indices))
+		for _, v := range indices {
+			r := map[string]interface{}{
+				"name":    v.Name,
+				"type":    v.Type,
+				"health":  v.Health,
+				"status":  v.Status,
+				"docs":    v.Docs,
+				"size":    v.Size,
+				"created": v.Created,
+			}
+
+			if len(v.Indices) > 0 {
+				r["indices"] = v.Indices
+			}
+
+			records = append(records, r)
+		}
+
+		var result io.Reader
+		switch format {
+		case "json":
+			result, err = output.JSONOutput(records)
+
+		case "table":
+			for _, r := range records {
+				r["size"] = humanSize(r["size"].(int64))
+				for _, k := range []string{"health", "status", "created"} {
+					if r[k] == "" {
+						delete(r, k)
+					}
+				}
+			}
+			result, err = output.TableOutput(records, indicesColumns)
+
+		default:
+			return fmt.Errorf("format='%s' is not implemented", format)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		io.Copy(os.Stdout, result)
+		return nil
+	}
+
+	return cmd
+}
+
+func AddIndicesCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getIndicesCommand())
+}
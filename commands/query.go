@@ -4,42 +4,39 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
 
 	"elastiq/client"
 	"elastiq/config"
 	q "elastiq/query"
-	"elastiq/source/datadog"
-	"elastiq/source/elasticsearch"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/pflag"
 )
 
 func getQueryCommand(name, usage string) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   name,
+		Use:   name + " [@saved-query]",
 		Short: usage,
+		Args:  cobra.MaximumNArgs(1),
 	}
 
 	cf := addCommonFlags(cmd)
 
+	of := addOutputFlags(cmd)
+
 	strs := []string{}
-	ascurl := false
-	recursive := ""
-	raw := false
 	limit := 0
 	timeRange := ""
 	orderBy := ""
+	validate := false
+	params := []string{}
 
 	pflags := cmd.PersistentFlags()
 	pflags.StringArrayVarP(&strs, "filter", "f", []string{}, "filter values like key=value")
-	pflags.BoolVarP(&ascurl, "curl", "", false, "output elasticsearch request as curl")
-	pflags.BoolVarP(&raw, "raw", "r", false, "toggle raw ouput from elasticsearch (disables output post processing)")
 	pflags.IntVarP(&limit, "limit", "l", 50, "specify limit for output records (specifying more than 10000 will apply paging)")
-	pflags.StringVarP(&recursive, "recursive", "R", "", "toggle recursive decoding")
 	pflags.StringVarP(&timeRange, "time", "t", "", "specify time filter as a/b (equivalent to -f '@timestamp intime a b'")
-	pflags.StringVarP(&orderBy, "orderby", "O", "", "specify records order (defaults to descending by @timestamp)")
+	pflags.StringVarP(&orderBy, "orderby", "O", "", "specify records order as comma separated key/direction[/first|last][/unmapped=type] (defaults to descending by @timestamp)")
+	pflags.BoolVarP(&validate, "validate", "V", false, "validate filters against index mapping and pick operations by field types")
+	pflags.StringArrayVarP(&params, "param", "", []string{}, "set parameter of saved query like key=value")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.ReadConfig(cf.config)
@@ -47,87 +44,129 @@ func getQueryCommand(name, usage string) *cobra.Command {
 			return err
 		}
 
-		e, err := cfg.GetEnv(cf.env)
+		saved, err := getSavedQuery(cfg, args, params)
 		if err != nil {
 			return err
 		}
 
-		tz, err := e.GetTimezone(cf.tz)
-		if err != nil {
-			return err
+		// flags override saved query settings, filters are added to the saved ones
+		filters := append(append([]string{}, saved.Filters...), strs...)
+
+		if timeRange == "" {
+			timeRange = saved.Time
 		}
 
-		timeSettings := q.TimeFilterSettings{
-			TimeZone:   tz,
-			TimeFormat: e.GetTimeFormat(cf.tf),
+		if timeRange != "" {
+			tf, err := getTimeFilter(timeRange)
+			if err != nil {
+				return err
+			}
+
+			filters = append(filters, tf)
 		}
 
-		var client client.Client
-		switch e.Source {
-		case config.SourceElasticSearch:
-			client = elasticsearch.NewClient(cfg)
-		case config.SourceDataDog:
-			client = datadog.NewClient(cfg)
+		if !cmd.Flags().Changed("limit") && saved.Limit > 0 {
+			limit = saved.Limit
 		}
 
-		query := &q.Query{
-			Filters: []*q.Filter{},
+		index := cf.index
+		if index == "" {
+			index = saved.Index
 		}
 
 		if orderBy == "" {
-			orderBy = e.Order
+			orderBy = saved.Order
 		}
 
-		if orderBy != "" {
-			o, err := q.GetOrder(orderBy)
+		// query is composed for every env separately,
+		// since aliases, timezone and time format are env specific
+		build := func(e *config.Env, warn func(string)) (client.Client, *q.Query, error) {
+			tz, err := e.GetTimezone(cf.tz)
 			if err != nil {
-				return fmt.Errorf("failed to parse order: %w", err)
+				return nil, nil, err
 			}
-			query.Order = o
-		}
 
-		if timeRange != "" {
-			t := strings.Split(timeRange, "/")
-			if len(t) > 2 {
-				return fmt.Errorf("to many delimiters in timerange='%s'", timeRange)
+			timeSettings := q.TimeFilterSettings{
+				TimeZone:   tz,
+				TimeFormat: e.GetTimeFormat(cf.tf),
 			}
 
-			if len(t) == 1 {
-				t = append(t, "now")
+			c, err := getClient(cfg, e)
+			if err != nil {
+				return nil, nil, err
 			}
 
-			strs = append(strs, fmt.Sprintf("@timestamp intime '%s' '%s'", t[0], t[1]))
-		}
+			query := &q.Query{
+				Index:  index,
+				Output: cf.output,
+				Limit:  e.GetLimit(limit),
+			}
+
+			if query.Output == "" {
+				query.Output = saved.Output
+			}
+
+			order := orderBy
+			if order == "" {
+				order = e.Order
+			}
+
+			if order != "" {
+				query.Order, err = getOrder(order, cfg.GetAliases(e))
+				if err != nil {
+					return nil, nil, err
+				}
+			}
 
-		for _, v := range strs {
-			filter, err := q.ParseFilter(v, timeSettings, cfg.Aliases)
+			query.Filters, err = parseFilters(filters, timeSettings, cfg, e)
 			if err != nil {
-				return fmt.Errorf("failed to parse filter='%s': %w", v, err)
+				return nil, nil, err
+			}
+
+			// sources without mapped fields (e.g. datadog) are queried without validation,
+			// so an inherited validate_filters doesn't break them
+			_, canValidate := c.(client.FieldsClient)
+			validate := validate || e.Validate != nil && *e.Validate
+			if validate && !canValidate {
+				warn(fmt.Sprintf("source='%s' does not support filters validation, filters are not validated", e.Source))
+			}
+
+			if validate && canValidate {
+				mapping, err := getMapping(cmd.Context(), c, e, index)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to get mapping: %w", err)
+				}
+
+				warnings, err := q.ApplyMapping(query.Filters, mapping)
+				for _, w := range warnings {
+					warn(w)
+				}
+
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid filter: %w", err)
+				}
 			}
 
-			query.Filters = append(query.Filters, filter)
+			return c, query, nil
 		}
 
-		query.Index = cf.index
-		query.Output = cf.output
-		query.Limit = e.GetLimit(limit)
+		if config.IsMultiEnv(cf.env) {
+			return fanOut(cmd, cfg, cf, of, build)
+		}
 
-		options := q.Options{
-			Debug:     cf.debug,
-			FromStdin: cf.stdin,
-			Raw:       raw,
-			AsCurl:    ascurl,
-			Recursive: nil,
+		e, err := cfg.GetEnv(cf.env)
+		if err != nil {
+			return err
 		}
 
-		cmd.Flags().Visit(func(f *pflag.Flag) {
-			if f.Name == "recursive" {
-				rlist := strings.Split(recursive, ",")
-				options.Recursive = &rlist
-			}
+		c, query, err := build(e, func(w string) {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
 		})
+		if err != nil {
+			return err
+		}
 
-		result, err := client.Query(cmd.Context(), e, query, options)
+		result, err := c.Query(cmd.Context(), e, query, of.options(cmd, cf))
 		if err != nil {
 			return fmt.Errorf("failed to run query: %w", err)
 		}
@@ -0,0 +1,409 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/output"
+	q "elastiq/query"
+
+	"github.com/spf13/cobra"
+)
+
+// tracer follows correlation ids across envs collecting records they are found in
+type tracer struct {
+	cfg     *config.Config
+	trace   *config.Trace
+	envs    []*config.Env
+	names   []string
+	filters []string
+	index   string
+	output  string
+	limit   int
+	tz      string
+	tf      string
+	options q.Options
+}
+
+type traceRecord struct {
+	*client.Record
+	env string
+}
+
+// search finds records having one of ids in correlation field
+func (t *tracer) search(ctx context.Context, e *config.Env, field string, ids []string) ([]*client.Record, error) {
+	c, err := getClient(t.cfg, e)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, ok := c.(client.SearchClient)
+	if !ok {
+		return nil, fmt.Errorf("source='%s' does not support trace command", e.Source)
+	}
+
+	tz, err := e.GetTimezone(t.tz)
+	if err != nil {
+		return nil, err
+	}
+
+	timeSettings := q.TimeFilterSettings{
+		TimeZone:   tz,
+		TimeFormat: e.GetTimeFormat(t.tf),
+	}
+
+	// ids inside of encoded field can be found only as phrases of the whole field
+	key, _ := t.trace.Split(field)
+	if key == "" {
+		key = field
+	}
+
+	if alias, ok := t.cfg.GetAliases(e)[key]; ok {
+		key = alias
+	}
+
+	filters, err := parseFilters(t.filters, timeSettings, t.cfg, e)
+	if err != nil {
+		return nil, err
+	}
+
+	// ids are matched as they are, so they don't need quoting
+	filters = append(filters, &q.Filter{Key: key, Operation: q.IN, Value: ids})
+
+	query := &q.Query{
+		Filters: filters,
+		Index:   t.index,
+		Output:  t.output,
+		Limit:   e.GetLimit(t.limit),
+	}
+
+	records, err := sc.Search(ctx, e, query, t.options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) >= query.Limit {
+		fmt.Fprintf(os.Stderr, "warning: found %d records of field='%s', some records may be missing, consider increasing --limit\n", len(records), field)
+	}
+
+	return records, nil
+}
+
+// collect returns values of correlation fields of the record
+func (t *tracer) collect(r *client.Record) []string {
+	ids := []string{}
+	for _, field := range t.trace.Fields {
+		encoded, path := t.trace.Split(field)
+
+		root := r.Source
+		if encoded != "" {
+			v, ok := output.Lookup(r.Source, encoded)
+			if !ok {
+				continue
+			}
+
+			decoded, ok := output.RecursiveDecode(v, map[string]bool{"json": true, "http": true}).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			root = decoded
+		}
+
+		v, ok := output.Lookup(root, path)
+		if !ok {
+			continue
+		}
+
+		switch vv := v.(type) {
+		case nil:
+		case []interface{}:
+			for _, id := range vv {
+				if id != nil {
+					ids = append(ids, fmt.Sprint(id))
+				}
+			}
+		default:
+			ids = append(ids, fmt.Sprint(vv))
+		}
+	}
+
+	return ids
+}
+
+// run searches ids round by round following ids found in records,
+// rounds are limited by depth of trace settings
+func (t *tracer) run(ctx context.Context, id string) ([]*traceRecord, []string, error) {
+	seenIDs := map[string]bool{id: true}
+	followed := []string{}
+	seenRecords := map[string]bool{}
+	records := []*traceRecord{}
+
+	ids := []string{id}
+	for round := 0; round < t.trace.Depth && len(ids) > 0; round++ {
+		type result struct {
+			env     int
+			field   string
+			records []*client.Record
+			err     error
+		}
+
+		results := []*result{}
+		for i := range t.envs {
+			for _, field := range t.trace.Fields {
+				results = append(results, &result{env: i, field: field})
+			}
+		}
+
+		wg := sync.WaitGroup{}
+		for _, r := range results {
+			wg.Add(1)
+			go func(r *result) {
+				defer wg.Done()
+				r.records, r.err = t.search(ctx, t.envs[r.env], r.field, ids)
+			}(r)
+		}
+		wg.Wait()
+
+		followed = append(followed, ids...)
+		ids = []string{}
+		failed := 0
+		for _, r := range results {
+			if r.err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "env='%s': field='%s': %s\n", t.names[r.env], r.field, r.err)
+				continue
+			}
+
+			for _, rec := range r.records {
+				key := t.names[r.env] + "/" + rec.ID
+				if rec.ID != "" && seenRecords[key] {
+					continue
+				}
+				seenRecords[key] = true
+
+				records = append(records, &traceRecord{Record: rec, env: t.names[r.env]})
+				for _, v := range t.collect(rec) {
+					if !seenIDs[v] {
+						seenIDs[v] = true
+						ids = append(ids, v)
+					}
+				}
+			}
+		}
+
+		if failed == len(results) {
+			return nil, nil, fmt.Errorf("trace failed, all searches of id='%s' failed", id)
+		}
+	}
+
+	if len(ids) > 0 {
+		sort.Strings(ids)
+		fmt.Fprintf(os.Stderr, "warning: depth=%d reached, ids not followed: %s\n", t.trace.Depth, strings.Join(ids, ", "))
+	}
+
+	return records, followed, nil
+}
+
+// groupByService orders records chronologically and groups them by service,
+// groups are ordered by their first record
+func groupByService(records []*traceRecord) ([]string, map[string][]*traceRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i].Timestamp, records[j].Timestamp
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+
+		return a.Before(b)
+	})
+
+	services := []string{}
+	groups := map[string][]*traceRecord{}
+	for _, r := range records {
+		service := "-"
+		if r.Service != nil {
+			service = fmt.Sprint(r.Service)
+		}
+
+		if _, ok := groups[service]; !ok {
+			services = append(services, service)
+		}
+		groups[service] = append(groups[service], r)
+	}
+
+	return services, groups
+}
+
+func traceText(services []string, groups map[string][]*traceRecord, withEnv bool) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	for i, s := range services {
+		if i > 0 {
+			fmt.Fprintln(buf)
+		}
+		fmt.Fprintf(buf, "%s (%d)\n", s, len(groups[s]))
+
+		w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+		for _, r := range groups[s] {
+			ts := "-"
+			if !r.Timestamp.IsZero() {
+				ts = r.Timestamp.UTC().Format(time.RFC3339Nano)
+			}
+
+			message := "-"
+			if r.Message != nil {
+				message = strings.ReplaceAll(fmt.Sprint(r.Message), "\n", " ")
+			}
+
+			if withEnv {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", ts, r.env, message)
+			} else {
+				fmt.Fprintf(w, "  %s\t%s\n", ts, message)
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			return nil, fmt.Errorf("failed to write timeline: %w", err)
+		}
+	}
+
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+func traceJSON(services []string, groups map[string][]*traceRecord) (io.Reader, error) {
+	result := make([]map[string]interface{}, 0, len(services))
+	for _, s := range services {
+		records := make([]interface{}, 0, len(groups[s]))
+		for _, r := range groups[s] {
+			u := r.Unified()
+			u[EnvField] = r.env
+			records = append(records, u)
+		}
+
+		result = append(result, map[string]interface{}{
+			"service": s,
+			"records": records,
+		})
+	}
+
+	return output.JSONOutput(result)
+}
+
+func getTraceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trace [id]",
+		Short: "follow correlation ids across envs and show records as a timeline grouped by service",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	cf := addCommonFlags(cmd)
+
+	strs := []string{}
+	timeRange := ""
+	limit := 0
+	depth := 0
+	format := ""
+
+	pflags := cmd.PersistentFlags()
+	pflags.StringArrayVarP(&strs, "filter", "f", []string{}, "filter values like key=value")
+	pflags.StringVarP(&timeRange, "time", "t", "-24h", "specify time filter as a/b")
+	pflags.IntVarP(&limit, "limit", "l", 1000, "specify limit of records found by every search")
+	pflags.IntVarP(&depth, "depth", "", 0, "number of search rounds, 1 searches only the given id (defaults to trace depth from config)")
+	pflags.StringVarP(&format, "format", "F", "text", "output format (text or json)")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if format != "text" && format != "json" {
+			return fmt.Errorf("format='%s' is not implemented", format)
+		}
+
+		if cf.stdin {
+			return fmt.Errorf("--stdin is not supported by trace command")
+		}
+
+		cfg, err := config.ReadConfig(cf.config)
+		if err != nil {
+			return err
+		}
+
+		t := &tracer{
+			cfg:     cfg,
+			trace:   cfg.GetTrace(),
+			filters: strs,
+			index:   cf.index,
+			output:  cf.output,
+			limit:   limit,
+			tz:      cf.tz,
+			tf:      cf.tf,
+			options: q.Options{Debug: cf.debug, KeepSource: true},
+		}
+
+		if cmd.Flags().Changed("depth") {
+			if depth < 1 {
+				return fmt.Errorf("depth has to be positive, got %d", depth)
+			}
+			t.trace.Depth = depth
+		}
+
+		if timeRange != "" {
+			tf, err := getTimeFilter(timeRange)
+			if err != nil {
+				return err
+			}
+
+			t.filters = append(append([]string{}, t.filters...), tf)
+		}
+
+		t.names = []string{cf.env}
+		if config.IsMultiEnv(cf.env) {
+			t.names, err = cfg.MatchEnvs(cf.env)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, name := range t.names {
+			e, err := cfg.GetEnv(name)
+			if err != nil {
+				return err
+			}
+			t.envs = append(t.envs, e)
+		}
+
+		records, followed, err := t.run(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "found %d records by ids: %s\n", len(records), strings.Join(followed, ", "))
+
+		services, groups := groupByService(records)
+
+		var result io.Reader
+		if format == "json" {
+			result, err = traceJSON(services, groups)
+		} else {
+			result, err = traceText(services, groups, len(t.names) > 1)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		io.Copy(os.Stdout, result)
+		return nil
+	}
+
+	return cmd
+}
+
+func AddTraceCommand(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(getTraceCommand())
+}
@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"elastiq/client"
+	"elastiq/config"
+	q "elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+// traceServer returns elasticsearch server finding docs by match_phrase shoulds of the query,
+// searched values are recorded by field
+func traceServer(t *testing.T, docs map[string]map[string]interface{}) (*httptest.Server, map[string][]string) {
+	mu := sync.Mutex{}
+	searched := map[string][]string{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Query struct {
+				Bool struct {
+					Should []struct {
+						MatchPhrase map[string]string `json:"match_phrase"`
+					} `json:"should"`
+				} `json:"bool"`
+			} `json:"query"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		hits := []map[string]interface{}{}
+		for id, source := range docs {
+			for _, s := range body.Query.Bool.Should {
+				for field, value := range s.MatchPhrase {
+					if fmt.Sprint(source[field]) == value {
+						hits = append(hits, map[string]interface{}{"_id": id, "_source": source})
+					}
+				}
+			}
+		}
+
+		for _, s := range body.Query.Bool.Should {
+			for field, value := range s.MatchPhrase {
+				searched[field] = append(searched[field], value)
+			}
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"hits": map[string]interface{}{"hits": hits, "total": map[string]int{"value": len(hits)}},
+		}))
+	}))
+
+	return srv, searched
+}
+
+func traceDocs() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"d1": {"@timestamp": "2021-07-14T10:00:01Z", "service": "api", "message": "request", "request_id": "r1", "trace_id": "t1"},
+		"d2": {"@timestamp": "2021-07-14T10:00:02Z", "service": "db", "message": "query", "request_id": "r'2", "trace_id": "t1"},
+		"d3": {"@timestamp": "2021-07-14T10:00:00Z", "service": "worker", "message": "job", "request_id": "r'2"},
+		"d4": {"@timestamp": "2021-07-14T10:00:03Z", "service": "api", "message": "other", "trace_id": "t9"},
+	}
+}
+
+func newTestTracer(t *testing.T, srv *httptest.Server, depth int) *tracer {
+	cfg := fanOutConfig(t, srv, srv)
+	e, err := cfg.GetEnv("es")
+	require.NoError(t, err)
+
+	return &tracer{
+		cfg:     cfg,
+		trace:   &config.Trace{Fields: []string{"request_id", "trace_id"}, Depth: depth},
+		envs:    []*config.Env{e},
+		names:   []string{"es"},
+		limit:   100,
+		options: q.Options{KeepSource: true},
+	}
+}
+
+func traceIDs(records []*traceRecord) []string {
+	ids := []string{}
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+
+	return ids
+}
+
+func TestTracerRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int
+		records  []string
+		followed []string
+	}{
+		{
+			name:     "ids are followed until no new ones are found",
+			depth:    5,
+			records:  []string{"d1", "d2", "d3"},
+			followed: []string{"r1", "t1", "r'2"},
+		},
+		{
+			name:     "depth limits rounds",
+			depth:    2,
+			records:  []string{"d1", "d2"},
+			followed: []string{"r1", "t1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, searched := traceServer(t, traceDocs())
+			defer srv.Close()
+
+			records, followed, err := newTestTracer(t, srv, tt.depth).run(context.Background(), "r1")
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.records, traceIDs(records))
+			require.Equal(t, tt.followed, followed)
+
+			// ids are searched as they are, without quotes
+			for _, id := range tt.followed {
+				require.Contains(t, searched["request_id"], id)
+				require.Contains(t, searched["trace_id"], id)
+			}
+		})
+	}
+
+	t.Run("all searches failed", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		_, _, err := newTestTracer(t, srv, 3).run(context.Background(), "r1")
+		require.Error(t, err)
+	})
+}
+
+func TestTracerCollect(t *testing.T) {
+	tr := &tracer{trace: &config.Trace{
+		Fields: []string{"request_id", "trace_id", "http.headers.x-request-id"},
+		Decode: []string{"http"},
+	}}
+
+	tests := []struct {
+		name   string
+		source map[string]interface{}
+		ids    []string
+	}{
+		{
+			name:   "plain fields",
+			source: map[string]interface{}{"request_id": "r1", "trace_id": float64(42)},
+			ids:    []string{"r1", "42"},
+		},
+		{
+			name:   "list values without nulls",
+			source: map[string]interface{}{"request_id": []interface{}{"r1", nil, "r2"}, "trace_id": nil},
+			ids:    []string{"r1", "r2"},
+		},
+		{
+			name:   "field inside of decoded one",
+			source: map[string]interface{}{"http": `{"headers": {"x-request-id": "r3"}}`},
+			ids:    []string{"r3"},
+		},
+		{
+			name:   "field which failed to decode",
+			source: map[string]interface{}{"http": "not json"},
+			ids:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.ids, tr.collect(&client.Record{Source: tt.source}))
+		})
+	}
+}
+
+func TestGroupByService(t *testing.T) {
+	at := func(sec int) time.Time {
+		return time.Date(2021, 7, 14, 10, 0, sec, 0, time.UTC)
+	}
+
+	records := []*traceRecord{
+		{Record: &client.Record{ID: "db-1", Timestamp: at(3), Service: "db"}},
+		{Record: &client.Record{ID: "unknown", Timestamp: at(4)}},
+		{Record: &client.Record{ID: "api-no-time", Service: "api"}},
+		{Record: &client.Record{ID: "api-2", Timestamp: at(2), Service: "api"}},
+		{Record: &client.Record{ID: "api-1", Timestamp: at(1), Service: "api"}},
+	}
+
+	services, groups := groupByService(records)
+
+	// groups are ordered by their first record, records without timestamp go last
+	require.Equal(t, []string{"api", "db", "-"}, services)
+	require.Equal(t, []string{"api-1", "api-2", "api-no-time"}, traceIDs(groups["api"]))
+	require.Equal(t, []string{"db-1"}, traceIDs(groups["db"]))
+	require.Equal(t, []string{"unknown"}, traceIDs(groups["-"]))
+}
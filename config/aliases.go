@@ -0,0 +1,73 @@
+package config
+
+// GetAliases returns global aliases overridden by aliases of the env
+func (c *Config) GetAliases(e *Env) map[string]string {
+	aliases := map[string]string{}
+	for k, v := range c.Aliases {
+		aliases[k] = v
+	}
+
+	if e != nil {
+		for k, v := range e.Aliases {
+			aliases[k] = v
+		}
+	}
+
+	return aliases
+}
+
+// GetValueAliases returns value aliases by field they are applied to (aliases of fields are resolved),
+// value aliases of the env override global ones
+func (c *Config) GetValueAliases(e *Env) map[string]map[string][]string {
+	aliases := c.GetAliases(e)
+	result := map[string]map[string][]string{}
+
+	add := func(valueAliases map[string]map[string][]string) {
+		for field, values := range valueAliases {
+			if alias, ok := aliases[field]; ok {
+				field = alias
+			}
+
+			if result[field] == nil {
+				result[field] = map[string][]string{}
+			}
+
+			for k, v := range values {
+				result[field][k] = v
+			}
+		}
+	}
+
+	add(c.ValueAliases)
+	if e != nil {
+		add(e.ValueAliases)
+	}
+
+	return result
+}
+
+// resolveOutputAliases returns a copy of the output with aliases in only and exclude replaced by fields
+func (c *Config) resolveOutputAliases(e *Env, o *Output) *Output {
+	aliases := c.GetAliases(e)
+	resolve := func(keys []string) []string {
+		if keys == nil {
+			return nil
+		}
+
+		result := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if alias, ok := aliases[k]; ok {
+				k = alias
+			}
+			result = append(result, k)
+		}
+
+		return result
+	}
+
+	r := *o
+	r.Only = resolve(o.Only)
+	r.Exclude = resolve(o.Exclude)
+
+	return &r
+}
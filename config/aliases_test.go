@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliases(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[aliases]
+app   = "kubernetes.labels.app"
+level = "log.level"
+
+[value_aliases.level]
+err  = ["error", "fatal"]
+warn = ["warn", "warning"]
+
+[env.es]
+endpoints = ["http://localhost:9200"]
+
+[env.dd]
+endpoints = ["https://api.datadoghq.eu"]
+source    = "datadog"
+[env.dd.aliases]
+app   = "service"
+level = "status"
+[env.dd.value_aliases.status]
+err = ["error", "critical"]
+
+[output.short]
+only = ["app", "message"]
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	es, err := cfg.GetEnv("es")
+	require.NoError(t, err)
+
+	dd, err := cfg.GetEnv("dd")
+	require.NoError(t, err)
+
+	require.Equal(t, "kubernetes.labels.app", cfg.GetAliases(es)["app"])
+	require.Equal(t, "service", cfg.GetAliases(dd)["app"])
+
+	require.Equal(t, map[string]map[string][]string{
+		"log.level": {
+			"err":  {"error", "fatal"},
+			"warn": {"warn", "warning"},
+		},
+	}, cfg.GetValueAliases(es))
+
+	require.Equal(t, map[string]map[string][]string{
+		"status": {
+			"err":  {"error", "critical"},
+			"warn": {"warn", "warning"},
+		},
+	}, cfg.GetValueAliases(dd))
+
+	o, err := cfg.GetOutput(es, "short")
+	require.NoError(t, err)
+	require.Equal(t, []string{"kubernetes.labels.app", "message"}, o.Only)
+
+	o, err = cfg.GetOutput(dd, "short")
+	require.NoError(t, err)
+	require.Equal(t, []string{"service", "message"}, o.Only)
+
+	// configured output itself is not changed
+	require.Equal(t, []string{"app", "message"}, cfg.Outputs["short"].Only)
+}
@@ -3,11 +3,12 @@ package config
 import (
 	"encoding/base64"
 	"fmt"
-	"math/rand"
+	"net/url"
+	"os"
+	"path"
+	"sort"
 	"strings"
 	"time"
-
-	"github.com/BurntSushi/toml"
 )
 
 type Source string
@@ -17,26 +18,30 @@ const (
 	SourceElasticSearch Source = "elasticsearch"
 )
 
-type AuthHeaderSpecification struct {
-	Value   *string  `toml:"value"`
-	Command []string `toml:"command"`
+// AWS specifies SigV4 signing of requests to Amazon OpenSearch Service,
+// credentials are taken from static keys, profile or AWS_* environment variables (in this order)
+type AWS struct {
+	Region          string `toml:"region"`
+	Service         string `toml:"service"`
+	Profile         string `toml:"profile"`
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+	SessionToken    string `toml:"session_token"`
+	RoleARN         string `toml:"role_arn"`
+	ExternalID      string `toml:"external_id"`
+	STSEndpoint     string `toml:"sts_endpoint"`
 }
 
-func (ahs *AuthHeaderSpecification) GetValue() string {
-	if ahs.Value != nil {
-		return *ahs.Value
-	}
+type Authorization struct {
+	Header map[string]*AuthHeaderSpecification `toml:"header"`
 
-	if len(ahs.Command) > 0 {
-		// FIXME implement it
-		panic("not implemented yet")
-	}
+	AWS *AWS `toml:"aws"`
 
-	return ""
-}
+	OAuth2 *OAuth2 `toml:"oauth2"`
 
-type Authorization struct {
-	Header map[string]AuthHeaderSpecification `toml:"header"`
+	Bearer *struct {
+		Token string `toml:"token"`
+	} `toml:"bearer"`
 
 	Basic *struct {
 		User     string `toml:"user"`
@@ -49,40 +54,85 @@ type Authorization struct {
 	} `toml:"cloud"`
 }
 
+type TLS struct {
+	CAFile             string `toml:"ca_file"`
+	CertFile           string `toml:"cert_file"`
+	KeyFile            string `toml:"key_file"`
+	ServerName         string `toml:"server_name"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+}
+
 type DatadogEnv struct {
 	DDAPIKey string `toml:"dd_api_key"`
 	DDAppKey string `toml:"dd_personal_key"`
 }
 
 type Env struct {
+	Extends       string         `toml:"extends"`
 	Endpoints     []string       `toml:"endpoints"`
 	Authorization *Authorization `toml:"authorization"`
 	Index         string         `toml:"index"`
 	IsDefault     bool           `toml:"default"`
 	TZ            string         `toml:"timezone"`
-	Timezone      *time.Location `toml:"-"`
 	TimeFormat    string         `toml:"time_format"`
-	Limit         int            `toml:"limit"`
+	Limit         *int           `toml:"limit"`
 	Output        string         `toml:"output"`
 	Order         string         `toml:"order"`
 	Source        Source         `toml:"source"`
+	Validate      *bool          `toml:"validate_filters"`
+	TLS           *TLS           `toml:"tls"`
+	Sniff         *bool          `toml:"sniff"`
+	Proxy         string         `toml:"proxy"`
+	Timeout       *Duration      `toml:"timeout"`
+	MaxRetries    *int           `toml:"max_retries"`
+	Backoff       *Duration      `toml:"backoff"`
+	MaxBackoff    *Duration      `toml:"max_backoff"`
+
+	Aliases      map[string]string              `toml:"aliases"`
+	ValueAliases map[string]map[string][]string `toml:"value_aliases"`
+
+	// Record names common fields used when records of different sources are merged
+	Record *RecordFields `toml:"record"`
 
 	DatadogEnv
+
+	// Files lists config files the env is defined in
+	Files []string `toml:"-"`
+
+	prepared bool
 }
 
 type Output struct {
+	Extends   string          `toml:"extends"`
 	Format    string          `toml:"format"`
 	Exclude   []string        `toml:"exclude"`
 	Only      []string        `toml:"only"`
 	D         interface{}     `toml:"decode_recursively"`
 	Decode    map[string]bool `toml:"-"`
 	IsDefault bool            `toml:"default"`
+	Files     []string        `toml:"-"`
+
+	// Fields and DocValueFields are requested from elasticsearch besides _source,
+	// e.g. runtime fields or fields not stored in _source
+	Fields         []string `toml:"fields"`
+	DocValueFields []string `toml:"docvalue_fields"`
 }
 
 type Config struct {
+	Include []string           `toml:"include"`
 	Envs    map[string]*Env    `toml:"env"`
 	Outputs map[string]*Output `toml:"output"`
 	Aliases map[string]string  `toml:"aliases"`
+
+	Queries map[string]*SavedQuery `toml:"query"`
+
+	Trace *Trace `toml:"trace"`
+
+	// ValueAliases maps field to aliases of its values, e.g. level = {err = ["error", "fatal"]}
+	ValueAliases map[string]map[string][]string `toml:"value_aliases"`
+
+	// TrustedProjects are directories whose project configs can set endpoints, authorization and secrets
+	TrustedProjects []string `toml:"trusted_projects"`
 }
 
 func FromStringList(l []string) map[string]bool {
@@ -118,14 +168,9 @@ func addrFromCloudID(input string) (string, error) {
 	return fmt.Sprintf("%s%s.%s", scheme, parts[1], parts[0]), nil
 }
 
-func (e *Env) GetEndpoint() string {
-	rand.Seed(time.Now().UTC().UnixNano())
-	return e.Endpoints[rand.Intn(len(e.Endpoints))]
-}
-
 func (e *Env) GetTimezone(tz string) (*time.Location, error) {
-	if tz != "" {
-		return e.Timezone, nil
+	if tz == "" {
+		tz = e.TZ
 	}
 
 	timezone, err := time.LoadLocation(tz)
@@ -157,13 +202,112 @@ func (e *Env) GetLimit(limit int) int {
 		return limit
 	}
 
-	if e.Limit > 0 {
-		return e.Limit
+	if e.Limit != nil && *e.Limit > 0 {
+		return *e.Limit
 	}
 
 	return 10
 }
 
+// prepare resolves secrets and composes authorization headers,
+// it is done only for envs actually used, so secrets of other envs are never touched
+func (e *Env) prepare(name string) error {
+	if e.prepared {
+		return nil
+	}
+
+	if err := resolveSecrets(&e.DDAPIKey, &e.DDAppKey); err != nil {
+		return fmt.Errorf("env='%s': %w", name, err)
+	}
+
+	auth := e.Authorization
+	if auth == nil {
+		e.prepared = true
+		return nil
+	}
+
+	for k, v := range auth.Header {
+		if v.Value == nil {
+			continue
+		}
+
+		if err := resolveSecrets(v.Value); err != nil {
+			return fmt.Errorf("env='%s' header='%s': %w", name, k, err)
+		}
+	}
+
+	if auth.AWS != nil {
+		if err := resolveSecrets(&auth.AWS.AccessKeyID, &auth.AWS.SecretAccessKey, &auth.AWS.SessionToken); err != nil {
+			return fmt.Errorf("env='%s': %w", name, err)
+		}
+	}
+
+	authHeader := ""
+	if auth.Basic != nil {
+		if err := resolveSecrets(&auth.Basic.User, &auth.Basic.Password); err != nil {
+			return fmt.Errorf("env='%s': %w", name, err)
+		}
+
+		authHeader = fmt.Sprintf(
+			"Basic %s",
+			base64.StdEncoding.EncodeToString([]byte(auth.Basic.User+":"+auth.Basic.Password)),
+		)
+	}
+
+	if auth.Cloud != nil {
+		if err := resolveSecrets(&auth.Cloud.APIKey); err != nil {
+			return fmt.Errorf("env='%s': %w", name, err)
+		}
+
+		authHeader = "APIKey " + auth.Cloud.APIKey
+	}
+
+	if auth.Bearer != nil {
+		if err := resolveSecrets(&auth.Bearer.Token); err != nil {
+			return fmt.Errorf("env='%s': %w", name, err)
+		}
+
+		authHeader = "Bearer " + auth.Bearer.Token
+	}
+
+	var spec *AuthHeaderSpecification
+	if authHeader != "" {
+		spec = &AuthHeaderSpecification{Value: &authHeader}
+	}
+
+	if auth.OAuth2 != nil {
+		if err := resolveSecrets(&auth.OAuth2.ClientID, &auth.OAuth2.ClientSecret); err != nil {
+			return fmt.Errorf("env='%s': %w", name, err)
+		}
+
+		oauth2 := auth.OAuth2
+		spec = &AuthHeaderSpecification{provider: func(refresh bool) (string, time.Time, error) {
+			c, err := HTTPClient(e)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+
+			token, expires, err := oauth2.Token(c, refresh)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+
+			return "Bearer " + token, expires, nil
+		}}
+	}
+
+	if spec != nil {
+		if auth.Header == nil {
+			auth.Header = map[string]*AuthHeaderSpecification{}
+		}
+
+		auth.Header["Authorization"] = spec
+	}
+
+	e.prepared = true
+	return nil
+}
+
 func (c *Config) GetEnv(env string) (*Env, error) {
 	if env != "" {
 		e, ok := c.Envs[env]
@@ -171,11 +315,27 @@ func (c *Config) GetEnv(env string) (*Env, error) {
 			return nil, fmt.Errorf("env='%s' not found", env)
 		}
 
+		if len(e.Endpoints) == 0 {
+			return nil, fmt.Errorf("env='%s' has zero endpoints", env)
+		}
+
+		if err := e.prepare(env); err != nil {
+			return nil, err
+		}
+
 		return e, nil
 	}
 
-	for _, v := range c.Envs {
+	for k, v := range c.Envs {
 		if v.IsDefault {
+			if len(v.Endpoints) == 0 {
+				return nil, fmt.Errorf("env='%s' has zero endpoints", k)
+			}
+
+			if err := v.prepare(k); err != nil {
+				return nil, err
+			}
+
 			return v, nil
 		}
 	}
@@ -183,6 +343,64 @@ func (c *Config) GetEnv(env string) (*Env, error) {
 	return nil, fmt.Errorf("env was not specified and no default env was found in config")
 }
 
+// IsMultiEnv reports if env specification refers to several envs (a list or a glob pattern)
+func IsMultiEnv(env string) bool {
+	return strings.ContainsAny(env, ",*?[")
+}
+
+// MatchEnvs returns names of envs specified as a comma separated list of names or glob patterns,
+// envs without endpoints (used only as a base for others) are not matched by patterns
+func (c *Config) MatchEnvs(env string) ([]string, error) {
+	names := make([]string, 0, len(c.Envs))
+	for k := range c.Envs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	seen := map[string]bool{}
+	result := []string{}
+	for _, p := range strings.Split(env, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.ContainsAny(p, "*?[") {
+			if _, ok := c.Envs[p]; !ok {
+				return nil, fmt.Errorf("env='%s' not found", p)
+			}
+
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+			continue
+		}
+
+		matched := false
+		for _, name := range names {
+			ok, err := path.Match(p, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid env pattern='%s': %w", p, err)
+			}
+
+			if ok && len(c.Envs[name].Endpoints) > 0 {
+				matched = true
+				if !seen[name] {
+					seen[name] = true
+					result = append(result, name)
+				}
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("no env matches pattern='%s'", p)
+		}
+	}
+
+	return result, nil
+}
+
 func (c *Config) GetOutput(env *Env, output string) (*Output, error) {
 	if output == "" {
 		output = env.Output
@@ -194,122 +412,236 @@ func (c *Config) GetOutput(env *Env, output string) (*Output, error) {
 			return nil, fmt.Errorf("output='%s' not found", output)
 		}
 
-		return o, nil
+		return c.resolveOutputAliases(env, o), nil
 	}
 
 	for _, v := range c.Outputs {
 		if v.IsDefault {
-			return v, nil
+			return c.resolveOutputAliases(env, v), nil
 		}
 	}
 
 	return &Output{Format: "json"}, nil
 }
 
+// Validate checks the config and fills settings computed from it, only the first problem is returned
 func (c *Config) Validate() error {
+	if problems := c.Check(); len(problems) > 0 {
+		return problems[0]
+	}
+
+	return nil
+}
+
+// Check validates the config and fills settings computed from it, returning every problem found
+func (c *Config) Check() []error {
+	if err := c.resolveExtends(); err != nil {
+		return []error{err}
+	}
+
+	problems := []error{}
+
+	envs := make([]string, 0, len(c.Envs))
+	for k := range c.Envs {
+		envs = append(envs, k)
+	}
+	sort.Strings(envs)
+
+	outputs := make([]string, 0, len(c.Outputs))
+	for k := range c.Outputs {
+		outputs = append(outputs, k)
+	}
+	sort.Strings(outputs)
+
 	defaults := []string{}
-	for k, v := range c.Envs {
-		if v.IsDefault {
+	for _, k := range envs {
+		if c.Envs[k].IsDefault {
 			defaults = append(defaults, k)
 		}
 	}
 
 	if len(defaults) > 1 {
-		return fmt.Errorf("only one default env is allowed, found multiple: %s", strings.Join(defaults, ", "))
+		problems = append(problems, fmt.Errorf("only one default env is allowed, found multiple: %s", strings.Join(defaults, ", ")))
 	}
 
 	defaults = []string{}
-	for k, v := range c.Outputs {
-		if v.IsDefault {
+	for _, k := range outputs {
+		if c.Outputs[k].IsDefault {
 			defaults = append(defaults, k)
 		}
 	}
 
 	if len(defaults) > 1 {
-		return fmt.Errorf("only one default output is allowed, found multiple: %s", strings.Join(defaults, ", "))
+		problems = append(problems, fmt.Errorf("only one default output is allowed, found multiple: %s", strings.Join(defaults, ", ")))
 	}
 
-	for k, v := range c.Envs {
-		if v.Source == "" {
-			v.Source = SourceElasticSearch
+	extended := map[string]bool{}
+	for _, v := range c.Envs {
+		extended[v.Extends] = true
+	}
+
+	for _, k := range envs {
+		v := c.Envs[k]
+		for _, err := range v.check(k, extended[k]) {
+			problems = append(problems, locate(err, v.Files, "env", k))
 		}
+	}
 
-		if auth := v.Authorization; auth != nil {
-			authHeader := ""
-			if auth.Basic != nil {
-				authHeader = fmt.Sprintf(
-					"Basic %s",
-					base64.StdEncoding.EncodeToString([]byte(auth.Basic.User+":"+auth.Basic.Password)),
-				)
-			}
+	for _, k := range outputs {
+		v := c.Outputs[k]
+		if err := v.check(k); err != nil {
+			problems = append(problems, locate(err, v.Files, "output", k))
+		}
+	}
 
-			if auth.Cloud != nil {
-				ep, err := addrFromCloudID(auth.Cloud.CloudID)
-				if err != nil {
-					return fmt.Errorf("env='%s' has invalid cloud_id: %w", k, err)
-				}
+	queries := make([]string, 0, len(c.Queries))
+	for k := range c.Queries {
+		queries = append(queries, k)
+	}
+	sort.Strings(queries)
 
-				v.Endpoints = []string{ep}
+	for _, k := range queries {
+		problems = append(problems, c.Queries[k].check(k, c)...)
+	}
 
-				authHeader = "APIKey " + auth.Cloud.APIKey
-			}
+	if c.Trace != nil {
+		problems = append(problems, c.Trace.check()...)
+	}
 
-			if authHeader != "" {
-				if auth.Header == nil {
-					auth.Header = map[string]AuthHeaderSpecification{}
-				}
+	return problems
+}
 
-				auth.Header["Authorization"] = AuthHeaderSpecification{Value: &authHeader}
-			}
+func (v *Env) check(k string, extended bool) []error {
+	problems := []error{}
+
+	if v.Source == "" {
+		v.Source = SourceElasticSearch
+	}
+
+	if auth := v.Authorization; auth != nil && auth.Cloud != nil {
+		ep, err := addrFromCloudID(auth.Cloud.CloudID)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("env='%s' has invalid cloud_id: %w", k, err))
+		} else {
+			v.Endpoints = []string{ep}
 		}
+	}
 
-		if len(v.Endpoints) == 0 {
-			return fmt.Errorf("env='%s' has zero endpoints", k)
+	if auth := v.Authorization; auth != nil && auth.OAuth2 != nil {
+		if auth.OAuth2.TokenURL == "" || auth.OAuth2.ClientID == "" {
+			problems = append(problems, fmt.Errorf("env='%s' has oauth2 authorization without token_url or client_id", k))
 		}
 	}
 
-	for k, v := range c.Outputs {
-		switch vv := v.D.(type) {
-		case nil:
-			v.Decode = map[string]bool{}
+	if auth := v.Authorization; auth != nil && auth.AWS != nil {
+		if v.Source != SourceElasticSearch {
+			problems = append(problems, fmt.Errorf("env='%s' has aws authorization, which is supported only by elasticsearch source", k))
+		}
 
-		case bool:
-			if vv {
-				v.Decode = map[string]bool{
-					"http": true,
-					"json": true,
-				}
-			}
+		if auth.AWS.Region == "" {
+			problems = append(problems, fmt.Errorf("env='%s' has aws authorization without region", k))
+		}
+
+		switch auth.AWS.Service {
+		case "":
+			auth.AWS.Service = "es"
+		case "es", "aoss":
+		default:
+			problems = append(problems, fmt.Errorf("env='%s' has unknown aws service='%s', allowed values are es and aoss", k, auth.AWS.Service))
+		}
+	}
+
+	// envs used only as a base for others don't have to specify endpoints
+	if len(v.Endpoints) == 0 && !extended {
+		problems = append(problems, fmt.Errorf("env='%s' has zero endpoints", k))
+	}
+
+	if v.Proxy != "" {
+		if _, err := url.Parse(v.Proxy); err != nil {
+			problems = append(problems, fmt.Errorf("env='%s' has invalid proxy: %w", k, err))
+		}
+	}
+
+	if v.MaxRetries != nil && *v.MaxRetries < 0 {
+		problems = append(problems, fmt.Errorf("env='%s' has negative max_retries", k))
+	}
 
-		case []string:
-			v.Decode = map[string]bool{}
-			for _, vvv := range vv {
-				v.Decode[vvv] = true
+	if t := v.TLS; t != nil {
+		if (t.CertFile == "") != (t.KeyFile == "") {
+			problems = append(problems, fmt.Errorf("env='%s' must have both cert_file and key_file specified for client TLS authentication", k))
+		}
+
+		for _, p := range []*string{&t.CAFile, &t.CertFile, &t.KeyFile} {
+			ep, err := expandHome(*p)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("env='%s': %w", k, err))
+				break
 			}
+			*p = ep
+		}
+	}
+
+	return problems
+}
+
+func (v *Output) check(k string) error {
+	switch vv := v.D.(type) {
+	case nil:
+		v.Decode = map[string]bool{}
 
-		case []interface{}:
-			v.Decode = map[string]bool{}
-			for _, vvv := range vv {
-				v.Decode[fmt.Sprint(vvv)] = true
+	case bool:
+		if vv {
+			v.Decode = map[string]bool{
+				"http": true,
+				"json": true,
 			}
+		}
 
-		default:
-			return fmt.Errorf("can't handle %v (type=%T) as decode_recursively in output='%s', allowed values are bool or []string", vv, vv, k)
+	case []string:
+		v.Decode = map[string]bool{}
+		for _, vvv := range vv {
+			v.Decode[vvv] = true
+		}
+
+	case []interface{}:
+		v.Decode = map[string]bool{}
+		for _, vvv := range vv {
+			v.Decode[fmt.Sprint(vvv)] = true
 		}
+
+	default:
+		return fmt.Errorf("can't handle %v (type=%T) as decode_recursively in output='%s', allowed values are bool or []string", vv, vv, k)
 	}
 
 	return nil
 }
 
+// ConfigPaths returns configPath followed by project configs
+// found in the current directory and its parents, which are merged over it
+func ConfigPaths(configPath string) []string {
+	project := []string{}
+	if wd, err := os.Getwd(); err == nil {
+		project = FindProjectConfigs(wd)
+	}
+
+	// user config is not required when there is a project one
+	if _, err := os.Stat(configPath); os.IsNotExist(err) && len(project) > 0 {
+		return project
+	}
+
+	return append([]string{configPath}, project...)
+}
+
+// ReadConfig reads and validates config from configPath (with its includes) and project configs
 func ReadConfig(configPath string) (*Config, error) {
-	cfg := Config{}
-	if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to read config from file='%s': %w", configPath, err)
+	cfg, err := LoadConfig(ConfigPaths(configPath)...)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
-	return &cfg, nil
+	return cfg, nil
 }
@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "config.toml")
+	writeFiles(t, dir, map[string]string{
+		"config.toml": `
+[env.a]
+index   = "logs-*"
+default = true
+
+[env.b] # comment
+endpoints   = ["http://localhost:9200"]
+max_retries = -1
+default     = true
+
+[output.o]
+decode_recursively = 5
+`,
+	})
+
+	cfg, err := config.LoadConfig(p)
+	require.NoError(t, err)
+
+	problems := []string{}
+	for _, err := range cfg.Check() {
+		problems = append(problems, err.Error())
+	}
+
+	require.Equal(t, []string{
+		"only one default env is allowed, found multiple: a, b",
+		p + ":2: env='a' has zero endpoints",
+		p + ":6: env='b' has negative max_retries",
+		p + ":11: can't handle 5 (type=int64) as decode_recursively in output='o', allowed values are bool or []string",
+	}, problems)
+
+	require.EqualError(t, cfg.Validate(), problems[0])
+}
+
+func TestWriteMasked(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.prod]
+endpoints = ["http://localhost:9200"]
+[env.prod.authorization.basic]
+user     = "user"
+password = "password"
+[env.prod.authorization.header.X-Api-Key]
+value = "secret"
+[env.prod.authorization.header.X-Token]
+value = "${env:TOKEN}"
+[env.prod.authorization.header.Authorization]
+value = "Bearer ${env:TOKEN}"
+[env.prod.authorization.header.X-Auth]
+value = "${env:USER}:${file:~/.secrets/es}"
+
+[env.dd]
+endpoints       = ["https://api.datadoghq.eu"]
+source          = "datadog"
+dd_api_key      = "keyring:elastiq/dd"
+dd_personal_key = "secret"
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	buf := bytes.Buffer{}
+	require.NoError(t, cfg.WriteMasked(&buf))
+
+	shown := map[string]interface{}{}
+	_, err = toml.Decode(buf.String(), &shown)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]interface{}{
+		"env": map[string]interface{}{
+			"prod": map[string]interface{}{
+				"endpoints": []interface{}{"http://localhost:9200"},
+				"source":    "elasticsearch",
+				"authorization": map[string]interface{}{
+					"basic": map[string]interface{}{
+						"user":     "user",
+						"password": "********",
+					},
+					"header": map[string]interface{}{
+						"X-Api-Key":     map[string]interface{}{"value": "********"},
+						"X-Token":       map[string]interface{}{"value": "${env:TOKEN}"},
+						"Authorization": map[string]interface{}{"value": "********${env:TOKEN}"},
+						"X-Auth":        map[string]interface{}{"value": "${env:USER}********${file:~/.secrets/es}"},
+					},
+				},
+			},
+			"dd": map[string]interface{}{
+				"endpoints":       []interface{}{"https://api.datadoghq.eu"},
+				"source":          "datadog",
+				"dd_api_key":      "keyring:elastiq/dd",
+				"dd_personal_key": "********",
+			},
+		},
+	}, shown)
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration allows to specify durations in config as strings like "10s" or "1h30m"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("failed to parse duration='%s': %w", string(text), err)
+	}
+
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Or returns the duration or def if the duration was not specified
+func (d *Duration) Or(def time.Duration) time.Duration {
+	if d == nil || d.Duration == 0 {
+		return def
+	}
+
+	return d.Duration
+}
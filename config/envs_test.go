@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchEnvs(t *testing.T) {
+	endpoints := []string{"http://localhost:9200"}
+	cfg := &config.Config{
+		Envs: map[string]*config.Env{
+			"base":    {},
+			"prod-eu": {Endpoints: endpoints},
+			"prod-us": {Endpoints: endpoints},
+			"staging": {Endpoints: endpoints},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		env    string
+		output []string
+		err    string
+	}{
+		{name: "list", env: "staging,prod-eu", output: []string{"staging", "prod-eu"}},
+		{name: "pattern", env: "prod-*", output: []string{"prod-eu", "prod-us"}},
+		{name: "pattern skips envs without endpoints", env: "*", output: []string{"prod-eu", "prod-us", "staging"}},
+		{name: "duplicates", env: "prod-eu, prod-*", output: []string{"prod-eu", "prod-us"}},
+		{name: "unknown env", env: "prod-eu,dev", err: "env='dev' not found"},
+		{name: "nothing matched", env: "dev-*", err: "no env matches pattern='dev-*'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := cfg.MatchEnvs(tt.env)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.output, names)
+		})
+	}
+
+	require.True(t, config.IsMultiEnv("a,b"))
+	require.True(t, config.IsMultiEnv("prod-*"))
+	require.False(t, config.IsMultiEnv("prod"))
+}
+
+func TestGetRecordFields(t *testing.T) {
+	es := &config.Env{Source: config.SourceElasticSearch}
+	require.Equal(t, config.RecordFields{Timestamp: "@timestamp", Message: "message", Service: "service"}, es.GetRecordFields())
+
+	dd := &config.Env{Source: config.SourceDataDog}
+	require.Equal(t, config.RecordFields{Timestamp: "timestamp", Message: "message", Service: "service"}, dd.GetRecordFields())
+
+	configured := &config.Env{
+		Source: config.SourceElasticSearch,
+		Record: &config.RecordFields{Message: "log", Service: "kubernetes.labels.app"},
+	}
+	require.Equal(t, config.RecordFields{Timestamp: "@timestamp", Message: "log", Service: "kubernetes.labels.app"}, configured.GetRecordFields())
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resolveExtends fills settings not specified in envs and outputs from the ones they extend
+func (c *Config) resolveExtends() error {
+	envs := map[string]string{}
+	for k, v := range c.Envs {
+		envs[k] = v.Extends
+	}
+
+	err := resolveInheritance("env", envs, func(child, parent string) {
+		c.Envs[child].inherit(c.Envs[parent])
+	})
+	if err != nil {
+		return err
+	}
+
+	outputs := map[string]string{}
+	for k, v := range c.Outputs {
+		outputs[k] = v.Extends
+	}
+
+	return resolveInheritance("output", outputs, func(child, parent string) {
+		c.Outputs[child].inherit(c.Outputs[parent])
+	})
+}
+
+// resolveInheritance calls merge for every child after its parent was resolved itself,
+// parents maps every known name to the name it extends (or empty string)
+func resolveInheritance(kind string, parents map[string]string, merge func(child, parent string)) error {
+	names := make([]string, 0, len(parents))
+	for k := range parents {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	done := map[string]bool{}
+	var resolve func(name string, path []string) error
+	resolve = func(name string, path []string) error {
+		if done[name] {
+			return nil
+		}
+
+		for i, p := range path {
+			if p == name {
+				cycle := append(path[i:], name)
+				return fmt.Errorf("%s='%s' has cyclic extends: %s", kind, name, strings.Join(cycle, " -> "))
+			}
+		}
+
+		parent := parents[name]
+		if parent != "" {
+			if _, ok := parents[parent]; !ok {
+				return fmt.Errorf("%s='%s' extends unknown %s='%s'", kind, name, kind, parent)
+			}
+
+			if err := resolve(parent, append(path, name)); err != nil {
+				return err
+			}
+
+			merge(name, parent)
+		}
+
+		done[name] = true
+		return nil
+	}
+
+	for _, name := range names {
+		if err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inherit takes from p every setting which is not specified in e, default flag is never inherited
+func (e *Env) inherit(p *Env) {
+	if len(e.Endpoints) == 0 {
+		e.Endpoints = append([]string(nil), p.Endpoints...)
+	}
+
+	if e.Authorization == nil {
+		e.Authorization = p.Authorization.clone()
+	}
+
+	if e.TLS == nil && p.TLS != nil {
+		t := *p.TLS
+		e.TLS = &t
+	}
+
+	for _, f := range []struct{ child, parent *string }{
+		{&e.Index, &p.Index},
+		{&e.TZ, &p.TZ},
+		{&e.TimeFormat, &p.TimeFormat},
+		{&e.Output, &p.Output},
+		{&e.Order, &p.Order},
+		{&e.Proxy, &p.Proxy},
+		{&e.DDAPIKey, &p.DDAPIKey},
+		{&e.DDAppKey, &p.DDAppKey},
+	} {
+		if *f.child == "" {
+			*f.child = *f.parent
+		}
+	}
+
+	if e.Source == "" {
+		e.Source = p.Source
+	}
+
+	if e.Limit == nil {
+		e.Limit = p.Limit
+	}
+
+	if e.MaxRetries == nil {
+		e.MaxRetries = p.MaxRetries
+	}
+
+	if e.Timeout == nil {
+		e.Timeout = p.Timeout
+	}
+
+	if e.Backoff == nil {
+		e.Backoff = p.Backoff
+	}
+
+	if e.MaxBackoff == nil {
+		e.MaxBackoff = p.MaxBackoff
+	}
+
+	if e.Record == nil && p.Record != nil {
+		r := *p.Record
+		e.Record = &r
+	}
+
+	for k, v := range p.Aliases {
+		if _, ok := e.Aliases[k]; !ok {
+			if e.Aliases == nil {
+				e.Aliases = map[string]string{}
+			}
+			e.Aliases[k] = v
+		}
+	}
+
+	for field, values := range p.ValueAliases {
+		if e.ValueAliases == nil {
+			e.ValueAliases = map[string]map[string][]string{}
+		}
+
+		if e.ValueAliases[field] == nil {
+			e.ValueAliases[field] = map[string][]string{}
+		}
+
+		for k, v := range values {
+			if _, ok := e.ValueAliases[field][k]; !ok {
+				e.ValueAliases[field][k] = v
+			}
+		}
+	}
+
+	if e.Validate == nil {
+		e.Validate = p.Validate
+	}
+
+	if e.Sniff == nil {
+		e.Sniff = p.Sniff
+	}
+}
+
+// clone makes a deep copy, so secrets and headers prepared for one env don't leak into another
+func (a *Authorization) clone() *Authorization {
+	if a == nil {
+		return nil
+	}
+
+	c := &Authorization{}
+	if a.Header != nil {
+		c.Header = make(map[string]*AuthHeaderSpecification, len(a.Header))
+		for k, v := range a.Header {
+			h := &AuthHeaderSpecification{
+				Command:  v.Command,
+				Timeout:  v.Timeout,
+				CacheTTL: v.CacheTTL,
+			}
+
+			if v.Value != nil {
+				value := *v.Value
+				h.Value = &value
+			}
+
+			c.Header[k] = h
+		}
+	}
+
+	if a.AWS != nil {
+		aws := *a.AWS
+		c.AWS = &aws
+	}
+
+	if a.OAuth2 != nil {
+		oauth2 := *a.OAuth2
+		c.OAuth2 = &oauth2
+	}
+
+	if a.Bearer != nil {
+		bearer := *a.Bearer
+		c.Bearer = &bearer
+	}
+
+	if a.Basic != nil {
+		basic := *a.Basic
+		c.Basic = &basic
+	}
+
+	if a.Cloud != nil {
+		cloud := *a.Cloud
+		c.Cloud = &cloud
+	}
+
+	return c
+}
+
+// inherit takes from p every setting which is not specified in o, default flag is never inherited
+func (o *Output) inherit(p *Output) {
+	if o.Format == "" {
+		o.Format = p.Format
+	}
+
+	if o.Exclude == nil {
+		o.Exclude = append([]string(nil), p.Exclude...)
+	}
+
+	if o.Only == nil {
+		o.Only = append([]string(nil), p.Only...)
+	}
+
+	if o.D == nil {
+		o.D = p.D
+	}
+
+	if o.Fields == nil {
+		o.Fields = append([]string(nil), p.Fields...)
+	}
+
+	if o.DocValueFields == nil {
+		o.DocValueFields = append([]string(nil), p.DocValueFields...)
+	}
+}
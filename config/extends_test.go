@@ -0,0 +1,184 @@
+package config_test
+
+import (
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtends(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.base]
+index       = "logs-*"
+timezone    = "Europe/Moscow"
+time_format = "2006-01-02 15:04:05"
+limit       = 100
+order       = "@timestamp/asc"
+[env.base.authorization.basic]
+user     = "user"
+password = "password"
+
+[env.prod]
+extends   = "base"
+endpoints = ["http://prod:9200"]
+default   = true
+
+[env.prod-eu]
+extends   = "prod"
+endpoints = ["http://prod-eu:9200"]
+index     = "logs-eu-*"
+default   = false
+
+[output.base]
+format  = "json"
+exclude = ["kubernetes"]
+
+[output.short]
+extends = "base"
+only    = ["message"]
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	e, err := cfg.GetEnv("prod-eu")
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://prod-eu:9200"}, e.Endpoints)
+	require.Equal(t, "logs-eu-*", e.Index)
+	require.Equal(t, "Europe/Moscow", e.TZ)
+	require.Equal(t, "2006-01-02 15:04:05", e.TimeFormat)
+	require.Equal(t, 100, e.GetLimit(0))
+	require.Equal(t, "@timestamp/asc", e.Order)
+	require.Equal(t, config.SourceElasticSearch, e.Source)
+	require.False(t, e.IsDefault)
+
+	header, err := e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Basic dXNlcjpwYXNzd29yZA==", header)
+
+	// authorization is copied, so preparing one env doesn't touch its parent
+	require.Nil(t, cfg.Envs["base"].Authorization.Header)
+
+	e, err = cfg.GetEnv("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://prod:9200"}, e.Endpoints)
+
+	_, err = cfg.GetEnv("base")
+	require.Error(t, err)
+
+	o, err := cfg.GetOutput(e, "short")
+	require.NoError(t, err)
+	require.Equal(t, "json", o.Format)
+	require.Equal(t, []string{"kubernetes"}, o.Exclude)
+	require.Equal(t, []string{"message"}, o.Only)
+	require.False(t, o.IsDefault)
+}
+
+func TestExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		err  string
+	}{
+		{
+			name: "unknown env",
+			cfg: `
+[env.prod]
+extends   = "base"
+endpoints = ["http://prod:9200"]
+`,
+			err: "env='prod' extends unknown env='base'",
+		},
+		{
+			name: "env cycle",
+			cfg: `
+[env.a]
+extends = "b"
+[env.b]
+extends = "c"
+[env.c]
+extends = "a"
+`,
+			err: "env='a' has cyclic extends: a -> b -> c -> a",
+		},
+		{
+			name: "env extends itself",
+			cfg: `
+[env.a]
+extends = "a"
+`,
+			err: "env='a' has cyclic extends: a -> a",
+		},
+		{
+			name: "unknown output",
+			cfg: `
+[output.short]
+extends = "base"
+`,
+			err: "output='short' extends unknown output='base'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{}
+			_, err := toml.Decode(tt.cfg, &cfg)
+			require.NoError(t, err)
+			require.EqualError(t, cfg.Validate(), tt.err)
+		})
+	}
+}
+
+func TestExtendsExplicitZeroValues(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.base]
+endpoints        = ["http://base:9200"]
+limit            = 100
+max_retries      = 3
+validate_filters = true
+sniff            = true
+
+[env.prod]
+extends = "base"
+
+[env.local]
+extends          = "base"
+limit            = 0
+max_retries      = 0
+validate_filters = false
+sniff            = false
+
+[env.local-eu]
+extends = "local"
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	tests := []struct {
+		env      string
+		limit    int
+		retries  int
+		validate bool
+		sniff    bool
+	}{
+		{env: "prod", limit: 100, retries: 3, validate: true, sniff: true},
+		// settings turned off are not taken from the parent
+		{env: "local", limit: 10, retries: 0, validate: false, sniff: false},
+		{env: "local-eu", limit: 10, retries: 0, validate: false, sniff: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			e, err := cfg.GetEnv(tt.env)
+			require.NoError(t, err)
+			require.Equal(t, tt.limit, e.GetLimit(0))
+			require.Equal(t, tt.retries, *e.MaxRetries)
+			require.Equal(t, tt.validate, *e.Validate)
+			require.Equal(t, tt.sniff, *e.Sniff)
+		})
+	}
+}
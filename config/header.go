@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"elastiq/cache"
+)
+
+const defaultCommandTimeout = 10 * time.Second
+
+type AuthHeaderSpecification struct {
+	Value   *string  `toml:"value"`
+	Command []string `toml:"command"`
+	// Timeout limits command execution time
+	Timeout *Duration `toml:"timeout"`
+	// CacheTTL specifies how long command output is stored on disk and reused by subsequent runs
+	CacheTTL *Duration `toml:"cache_ttl"`
+
+	// provider computes the value for authorization mechanisms other than plain headers,
+	// along with the time the value expires at (zero time if it is unknown)
+	provider func(refresh bool) (string, time.Time, error)
+
+	mu      sync.Mutex
+	fetched bool
+	refresh bool
+	value   string
+	expires time.Time
+	err     error
+}
+
+// GetValue returns the header value, the value obtained from a command or a provider is reused
+// until it expires or is invalidated
+func (ahs *AuthHeaderSpecification) GetValue() (string, error) {
+	if ahs.Value != nil {
+		return *ahs.Value, nil
+	}
+
+	if !ahs.Refreshable() {
+		return "", nil
+	}
+
+	ahs.mu.Lock()
+	defer ahs.mu.Unlock()
+
+	expired := !ahs.expires.IsZero() && !time.Now().Before(ahs.expires)
+	if !ahs.fetched || ahs.refresh || expired {
+		if ahs.provider != nil {
+			ahs.value, ahs.expires, ahs.err = ahs.provider(ahs.refresh || expired)
+		} else {
+			ahs.value, ahs.err = ahs.fromCommand(ahs.refresh)
+		}
+
+		ahs.fetched = true
+		ahs.refresh = false
+	}
+
+	return ahs.value, ahs.err
+}
+
+// Refreshable tells whether the value is obtained from a command or a provider and can be obtained again
+func (ahs *AuthHeaderSpecification) Refreshable() bool {
+	return ahs.Value == nil && (len(ahs.Command) > 0 || ahs.provider != nil)
+}
+
+// Invalidate makes the next GetValue obtain a new value bypassing the cache, e.g. when the value is rejected
+func (ahs *AuthHeaderSpecification) Invalidate() {
+	ahs.mu.Lock()
+	defer ahs.mu.Unlock()
+
+	ahs.refresh = true
+}
+
+// RefreshAuthorization invalidates authorization header values which can be obtained again
+// (e.g. oauth2 tokens or command output), returns false if there are no such values
+func (e *Env) RefreshAuthorization() bool {
+	if e.Authorization == nil {
+		return false
+	}
+
+	refreshed := false
+	for _, v := range e.Authorization.Header {
+		if v.Refreshable() {
+			v.Invalidate()
+			refreshed = true
+		}
+	}
+
+	return refreshed
+}
+
+func (ahs *AuthHeaderSpecification) fromCommand(refresh bool) (string, error) {
+	key := strings.Join(ahs.Command, "\x00")
+	ttl := ahs.CacheTTL.Or(0)
+
+	if ttl > 0 && !refresh {
+		value := ""
+		if found, _ := cache.Load("auth_header", key, &value); found {
+			return value, nil
+		}
+	}
+
+	value, err := runCommand(ahs.Command, ahs.Timeout.Or(defaultCommandTimeout))
+	if err != nil {
+		return "", err
+	}
+
+	// the value is obtained anyway, so failing to cache it only costs running the command next time
+	if ttl > 0 {
+		if err := cache.Store("auth_header", key, value, ttl); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to cache header value: %s\n", err)
+		}
+	}
+
+	return value, nil
+}
+
+func runCommand(command []string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("command='%s' timed out after %s", strings.Join(command, " "), timeout)
+		}
+
+		return "", fmt.Errorf(
+			"command='%s' failed: %w, stderr='%s'",
+			strings.Join(command, " "), err, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	value := strings.TrimSpace(stdout.String())
+	if value == "" {
+		return "", fmt.Errorf("command='%s' returned empty output", strings.Join(command, " "))
+	}
+
+	return value, nil
+}
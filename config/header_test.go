@@ -0,0 +1,117 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"elastiq/cache"
+	"elastiq/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHeaderSpecification_GetValue(t *testing.T) {
+	value := "static value"
+
+	tests := []struct {
+		name   string
+		spec   *config.AuthHeaderSpecification
+		output string
+		err    bool
+	}{
+		{
+			name:   "static value",
+			spec:   &config.AuthHeaderSpecification{Value: &value},
+			output: "static value",
+		},
+		{
+			name:   "command output is trimmed",
+			spec:   &config.AuthHeaderSpecification{Command: []string{"echo", "  Bearer token  "}},
+			output: "Bearer token",
+		},
+		{
+			name: "failed command",
+			spec: &config.AuthHeaderSpecification{Command: []string{"sh", "-c", "echo oops >&2; exit 1"}},
+			err:  true,
+		},
+		{
+			name: "empty output",
+			spec: &config.AuthHeaderSpecification{Command: []string{"true"}},
+			err:  true,
+		},
+		{
+			name: "timeout",
+			spec: &config.AuthHeaderSpecification{
+				Command: []string{"sleep", "5"},
+				Timeout: &config.Duration{Duration: 100 * time.Millisecond},
+			},
+			err: true,
+		},
+		{
+			name: "unknown command",
+			spec: &config.AuthHeaderSpecification{Command: []string{"/nonexistent/command"}},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.spec.GetValue()
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, v)
+			}
+		})
+	}
+}
+
+func TestAuthHeaderSpecification_GetValueCached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv(cache.EnvCacheDir, dir)
+	defer os.Unsetenv(cache.EnvCacheDir)
+
+	// every run of the command returns a new value
+	command := []string{"sh", "-c", "date +%s%N"}
+	ttl := &config.Duration{Duration: time.Minute}
+
+	first := config.AuthHeaderSpecification{Command: command, CacheTTL: ttl}
+	v1, err := first.GetValue()
+	require.NoError(t, err)
+
+	second := config.AuthHeaderSpecification{Command: command, CacheTTL: ttl}
+	v2, err := second.GetValue()
+	require.NoError(t, err)
+	require.Equal(t, v1, v2)
+
+	uncached := config.AuthHeaderSpecification{Command: command}
+	v3, err := uncached.GetValue()
+	require.NoError(t, err)
+	require.NotEqual(t, v1, v3)
+}
+
+func TestAuthHeaderSpecification_GetValueCacheFailure(t *testing.T) {
+	f, err := ioutil.TempFile("", "elastiq-cache")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// cache dir can't be created in place of a file
+	os.Setenv(cache.EnvCacheDir, f.Name())
+	defer os.Unsetenv(cache.EnvCacheDir)
+
+	spec := config.AuthHeaderSpecification{
+		Command:  []string{"echo", "token"},
+		CacheTTL: &config.Duration{Duration: time.Minute},
+	}
+
+	v, err := spec.GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "token", v)
+}
@@ -0,0 +1,411 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ProjectConfigName is a name of config looked up in the current directory and its parents
+const ProjectConfigName = ".elastiq.toml"
+
+// FindProjectConfigs returns project configs found in dir and its parents,
+// the outermost goes first, so the innermost one overrides the others
+func FindProjectConfigs(dir string) []string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	found := []string{}
+	for {
+		p := filepath.Join(dir, ProjectConfigName)
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			found = append([]string{p}, found...)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return found
+		}
+		dir = parent
+	}
+}
+
+// untrustedEnvKey returns key of the first env setting an untrusted project config can not set,
+// since they decide where requests (along with credentials of the user) are sent and how they are authorized
+func untrustedEnvKey(e *Env) string {
+	switch {
+	case len(e.Endpoints) > 0:
+		return "endpoints"
+	case e.Authorization != nil:
+		return "authorization"
+	case e.TLS != nil:
+		return "tls"
+	case e.Proxy != "":
+		return "proxy"
+	case e.Source != "":
+		return "source"
+	case e.DDAPIKey != "":
+		return "dd_api_key"
+	case e.DDAppKey != "":
+		return "dd_personal_key"
+	}
+
+	return ""
+}
+
+// extendsCredentials returns name of a trusted env with endpoints, authorization or tls
+// the env inherits settings from, directly or through other envs
+func extendsCredentials(e *Env, project, trusted *Config) string {
+	seen := map[string]bool{}
+	for name := e.Extends; name != "" && !seen[name]; {
+		seen[name] = true
+
+		next := ""
+		if t, ok := trusted.Envs[name]; ok {
+			if len(t.Endpoints) > 0 || t.Authorization != nil || t.TLS != nil {
+				return name
+			}
+			next = t.Extends
+		}
+
+		if pe, ok := project.Envs[name]; ok && pe.Extends != "" {
+			next = pe.Extends
+		}
+		name = next
+	}
+
+	return ""
+}
+
+// isProjectConfig tells whether p is a project config, which is loaded only if it is trusted
+func isProjectConfig(p string) bool {
+	return filepath.Base(p) == ProjectConfigName
+}
+
+// isTrusted tells whether project config p is in one of trusted directories (or their subdirectories)
+func isTrusted(p string, trusted []string) bool {
+	dir := filepath.Dir(p)
+	for _, t := range trusted {
+		t, err := expandHome(t)
+		if err != nil {
+			continue
+		}
+
+		t, err = filepath.Abs(t)
+		if err != nil {
+			continue
+		}
+
+		if dir == t || strings.HasPrefix(dir, t+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkUntrusted returns error if untrusted project config p can run commands,
+// read secrets of the user or send requests to other hosts.
+// Decoded settings are checked, since toml matches keys to them case-insensitively
+func checkUntrusted(p string, raw map[string]interface{}, cfg, trusted *Config) error {
+	forbidden := func(key string) error {
+		return fmt.Errorf("project config='%s' is not trusted and can not set '%s', "+
+			"add its directory to trusted_projects of the user config to allow it", p, key)
+	}
+
+	if cfg.Include != nil {
+		return forbidden("include")
+	}
+
+	if cfg.TrustedProjects != nil {
+		return forbidden("trusted_projects")
+	}
+
+	names := make([]string, 0, len(cfg.Envs))
+	for name := range cfg.Envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		e := cfg.Envs[name]
+		if key := untrustedEnvKey(e); key != "" {
+			return forbidden("env." + name + "." + key)
+		}
+
+		// an extending env gets endpoints and credentials of the user along with settings it can override
+		if parent := extendsCredentials(e, cfg, trusted); parent != "" {
+			return fmt.Errorf("project config='%s' is not trusted and its env='%s' can not extend env='%s' having endpoints, authorization or tls, "+
+				"add its directory to trusted_projects of the user config to allow it", p, name, parent)
+		}
+	}
+
+	if key := findSecretReference(raw, ""); key != "" {
+		return forbidden(key)
+	}
+
+	return nil
+}
+
+// findSecretReference returns key of the first value referencing a secret
+func findSecretReference(v interface{}, key string) string {
+	switch vv := v.(type) {
+	case string:
+		if hasSecretReference(vv) {
+			return key
+		}
+
+	case map[string]interface{}:
+		for k, v := range vv {
+			sub := k
+			if key != "" {
+				sub = key + "." + k
+			}
+
+			if found := findSecretReference(v, sub); found != "" {
+				return found
+			}
+		}
+
+	case []interface{}:
+		for _, v := range vv {
+			if found := findSecretReference(v, key); found != "" {
+				return found
+			}
+		}
+
+	case []map[string]interface{}:
+		for _, v := range vv {
+			if found := findSecretReference(v, key); found != "" {
+				return found
+			}
+		}
+	}
+
+	return ""
+}
+
+type loader struct {
+	merged map[string]interface{}
+	files  map[string]map[string][]string
+	loaded map[string]bool
+}
+
+// LoadConfig reads config files merging every next one over the previous ones,
+// files included by a config are merged before the config itself.
+// The result is not validated
+func LoadConfig(paths ...string) (*Config, error) {
+	l := loader{
+		merged: map[string]interface{}{},
+		files:  map[string]map[string][]string{"env": {}, "output": {}},
+		loaded: map[string]bool{},
+	}
+
+	for _, p := range paths {
+		if err := l.load(p, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := l.config()
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range cfg.Envs {
+		v.Files = l.files["env"][k]
+	}
+
+	for k, v := range cfg.Outputs {
+		v.Files = l.files["output"][k]
+	}
+
+	return cfg, nil
+}
+
+// config decodes files merged so far
+func (l *loader) config() (*Config, error) {
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(l.merged); err != nil {
+		return nil, fmt.Errorf("failed to merge config files: %w", err)
+	}
+
+	cfg := Config{}
+	if _, err := toml.Decode(buf.String(), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to merge config files: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+func (l *loader) load(p string, stack []string) error {
+	p, err := expandHome(p)
+	if err != nil {
+		return err
+	}
+
+	p, err = filepath.Abs(p)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of config file='%s': %w", p, err)
+	}
+
+	for i, s := range stack {
+		if s == p {
+			return fmt.Errorf("config files include each other: %s", strings.Join(append(stack[i:], p), " -> "))
+		}
+	}
+
+	// the same file can be included by several configs
+	if l.loaded[p] {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return fmt.Errorf("failed to read config from file='%s': %w", p, err)
+	}
+
+	raw := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		return fmt.Errorf("failed to read config from file='%s': %w", p, err)
+	}
+
+	// check types here, merged config has no idea which file a value came from
+	cfg := Config{}
+	if _, err := toml.Decode(string(data), &cfg); err != nil {
+		return fmt.Errorf("failed to read config from file='%s': %w", p, err)
+	}
+
+	// trusted projects are taken only from files loaded before project configs
+	if isProjectConfig(p) {
+		trusted, err := l.config()
+		if err != nil {
+			return err
+		}
+
+		if !isTrusted(p, trusted.TrustedProjects) {
+			if err := checkUntrusted(p, raw, &cfg, trusted); err != nil {
+				return err
+			}
+		} else if cfg.TrustedProjects != nil {
+			return fmt.Errorf("project config='%s' can not set 'trusted_projects'", p)
+		}
+	}
+
+	delete(raw, "include")
+	for _, inc := range cfg.Include {
+		inc, err := expandHome(inc)
+		if err != nil {
+			return fmt.Errorf("failed to include '%s' in config file='%s': %w", inc, p, err)
+		}
+
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(p), inc)
+		}
+
+		if err := l.load(inc, append(stack, p)); err != nil {
+			return err
+		}
+	}
+
+	for kind, names := range l.files {
+		table, _ := raw[kind].(map[string]interface{})
+		for name, v := range table {
+			names[name] = append(names[name], p)
+
+			// default from a later file wins over the ones from previous files
+			if t, ok := v.(map[string]interface{}); ok && t["default"] == true {
+				clearDefaults(l.merged[kind], name)
+			}
+		}
+	}
+
+	mergeTables(l.merged, raw)
+	l.loaded[p] = true
+
+	return nil
+}
+
+func clearDefaults(v interface{}, except string) {
+	table, _ := v.(map[string]interface{})
+	for name, v := range table {
+		if t, ok := v.(map[string]interface{}); ok && name != except && t["default"] == true {
+			t["default"] = false
+		}
+	}
+}
+
+// mergeTables merges src into dst, nested tables are merged key by key,
+// any other value (including arrays) is replaced
+func mergeTables(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sv, ok := v.(map[string]interface{}); ok {
+			if dv, ok := dst[k].(map[string]interface{}); ok {
+				mergeTables(dv, sv)
+				continue
+			}
+		}
+
+		dst[k] = v
+	}
+}
+
+// LocatedError is a config problem with location of the env or output it relates to
+type LocatedError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *LocatedError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+func (e *LocatedError) Unwrap() error {
+	return e.Err
+}
+
+// locate points err to the table of kind.name in the last of files defining it
+func locate(err error, files []string, kind, name string) error {
+	if len(files) == 0 {
+		return err
+	}
+
+	table := kind + "." + name
+	for i := len(files) - 1; i >= 0; i-- {
+		data, rerr := ioutil.ReadFile(files[i])
+		if rerr != nil {
+			continue
+		}
+
+		for n, line := range strings.Split(string(data), "\n") {
+			if c := strings.Index(line, "#"); c >= 0 {
+				line = line[:c]
+			}
+
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "[") {
+				continue
+			}
+
+			header := strings.NewReplacer("[", "", "]", "", "\"", "", "'", "", " ", "").Replace(line)
+			if header == table || strings.HasPrefix(header, table+".") {
+				return &LocatedError{File: files[i], Line: n + 1, Err: err}
+			}
+		}
+	}
+
+	return &LocatedError{File: files[len(files)-1], Err: err}
+}
@@ -0,0 +1,238 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+		require.NoError(t, ioutil.WriteFile(p, []byte(content), 0600))
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"config.toml": `
+include = ["team/team.toml", "elastiq.local.toml"]
+
+[env.prod.authorization.basic]
+user     = "user"
+password = "password"
+`,
+		"team/team.toml": `
+[env.prod]
+endpoints = ["http://prod:9200"]
+index     = "logs-*"
+default   = true
+
+[env.stage]
+endpoints = ["http://stage:9200"]
+index     = "logs-*"
+
+[output.short]
+only   = ["message"]
+format = "json"
+
+[aliases]
+app = "kubernetes.labels.app"
+`,
+		"elastiq.local.toml": `
+[env.stage]
+index   = "stage-*"
+default = true
+`,
+		"project/.elastiq.toml": `
+[env.prod]
+limit = 50
+
+[aliases]
+env = "kubernetes.labels.environment"
+`,
+	})
+
+	paths := append([]string{filepath.Join(dir, "config.toml")}, config.FindProjectConfigs(filepath.Join(dir, "project"))...)
+	cfg, err := config.LoadConfig(paths...)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	prod := cfg.Envs["prod"]
+	require.Equal(t, []string{"http://prod:9200"}, prod.Endpoints)
+	require.Equal(t, "logs-*", prod.Index)
+	require.Equal(t, 50, prod.GetLimit(0))
+	require.Equal(t, "user", prod.Authorization.Basic.User)
+	require.False(t, prod.IsDefault)
+	require.Equal(t, []string{
+		filepath.Join(dir, "team", "team.toml"),
+		filepath.Join(dir, "config.toml"),
+		filepath.Join(dir, "project", ".elastiq.toml"),
+	}, prod.Files)
+
+	stage := cfg.Envs["stage"]
+	require.Equal(t, []string{"http://stage:9200"}, stage.Endpoints)
+	require.Equal(t, "stage-*", stage.Index)
+	require.True(t, stage.IsDefault)
+
+	require.Equal(t, []string{"message"}, cfg.Outputs["short"].Only)
+	require.Equal(t, map[string]string{
+		"app": "kubernetes.labels.app",
+		"env": "kubernetes.labels.environment",
+	}, cfg.Aliases)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"a.toml":       `include = ["b.toml"]`,
+		"b.toml":       `include = ["a.toml"]`,
+		"invalid.toml": "[env.prod]\nlimit = \"ten\"",
+		"typo.toml":    `include = ["missing.toml"]`,
+	})
+
+	_, err = config.LoadConfig(filepath.Join(dir, "a.toml"))
+	require.EqualError(t, err, "config files include each other: "+
+		filepath.Join(dir, "a.toml")+" -> "+filepath.Join(dir, "b.toml")+" -> "+filepath.Join(dir, "a.toml"))
+
+	_, err = config.LoadConfig(filepath.Join(dir, "invalid.toml"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), filepath.Join(dir, "invalid.toml"))
+
+	_, err = config.LoadConfig(filepath.Join(dir, "missing.toml"))
+	require.Error(t, err)
+
+	_, err = config.LoadConfig(filepath.Join(dir, "typo.toml"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), filepath.Join(dir, "missing.toml"))
+}
+
+func TestLoadConfigProjectTrust(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"config.toml": `
+[env.prod]
+endpoints = ["http://prod:9200"]
+`,
+		"trusting.toml": `
+trusted_projects = ["` + filepath.Join(dir, "trusted") + `"]
+
+[env.prod]
+endpoints = ["http://prod:9200"]
+`,
+		"safe/.elastiq.toml": `
+[env.prod]
+index = "logs-*"
+`,
+		"endpoints/.elastiq.toml": `
+[env.prod]
+endpoints = ["http://attacker:9200"]
+`,
+		"command/.elastiq.toml": `
+[env.prod.authorization.header.Authorization]
+command = ["sh", "-c", "id"]
+`,
+		"secret/.elastiq.toml": `
+[query.leak]
+filters = ["message=${env:HOME}"]
+`,
+		"mixed-case/.elastiq.toml": `
+[env.prod2]
+index = "logs-*"
+
+[env.prod2.Authorization.header.X]
+command = ["touch", "/tmp/pwned"]
+`,
+		"mixed-case-endpoints/.elastiq.toml": `
+[env.prod2]
+extends   = "prod"
+Endpoints = ["http://attacker:9200"]
+`,
+		"mixed-case-include/.elastiq.toml": `Include = ["../config.toml"]`,
+		"mixed-case-trust/.elastiq.toml":   `Trusted_Projects = ["/"]`,
+		"mixed-case-table/.elastiq.toml": `
+[Env.prod]
+ENDPOINTS = ["http://attacker:9200"]
+`,
+		"extends/.elastiq.toml": `
+[env.prod2]
+extends = "prod"
+default = true
+`,
+		"extends-chain/.elastiq.toml": `
+[env.base]
+index = "logs-*"
+extends = "prod"
+
+[env.prod2]
+extends = "base"
+`,
+		"extends-project/.elastiq.toml": `
+[env.base]
+index = "logs-*"
+
+[env.prod2]
+extends = "base"
+`,
+		"include/.elastiq.toml": `include = ["../config.toml"]`,
+		"trust/.elastiq.toml":   `trusted_projects = ["/"]`,
+		"trusted/app/.elastiq.toml": `
+[env.prod]
+endpoints = ["http://proxy:9200"]
+`,
+	})
+
+	tests := []struct {
+		name    string
+		config  string
+		project string
+		err     string
+	}{
+		{name: "safe settings", config: "config.toml", project: "safe"},
+		{name: "endpoints", config: "config.toml", project: "endpoints", err: "'env.prod.endpoints'"},
+		{name: "command header", config: "config.toml", project: "command", err: "'env.prod.authorization'"},
+		{name: "secret reference", config: "config.toml", project: "secret", err: "'query.leak.filters'"},
+		{name: "mixed case authorization", config: "config.toml", project: "mixed-case", err: "'env.prod2.authorization'"},
+		{name: "mixed case endpoints", config: "config.toml", project: "mixed-case-endpoints", err: "'env.prod2.endpoints'"},
+		{name: "mixed case include", config: "config.toml", project: "mixed-case-include", err: "'include'"},
+		{name: "mixed case trusted projects", config: "config.toml", project: "mixed-case-trust", err: "'trusted_projects'"},
+		{name: "mixed case env table", config: "config.toml", project: "mixed-case-table", err: "'env.prod.endpoints'"},
+		{name: "extends env with endpoints", config: "config.toml", project: "extends", err: "env='prod2' can not extend env='prod'"},
+		{name: "extends env with endpoints through project env", config: "config.toml", project: "extends-chain", err: "can not extend env='prod'"},
+		{name: "extends project env", config: "config.toml", project: "extends-project"},
+		{name: "include", config: "config.toml", project: "include", err: "'include'"},
+		{name: "trusting itself", config: "config.toml", project: "trust", err: "'trusted_projects'"},
+		{name: "trusted", config: "trusting.toml", project: "trusted/app"},
+		{name: "not trusted by other config", config: "config.toml", project: "trusted/app", err: "'env.prod.endpoints'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := append([]string{filepath.Join(dir, tt.config)}, config.FindProjectConfigs(filepath.Join(dir, tt.project))...)
+			_, err := config.LoadConfig(paths...)
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
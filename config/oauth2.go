@@ -0,0 +1,128 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"elastiq/cache"
+)
+
+const oauth2Timeout = 30 * time.Second
+
+// OAuth2 specifies client credentials grant used to obtain access tokens
+type OAuth2 struct {
+	TokenURL     string   `toml:"token_url"`
+	ClientID     string   `toml:"client_id"`
+	ClientSecret string   `toml:"client_secret"`
+	Scopes       []string `toml:"scopes"`
+}
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// HTTPClient returns http client for requests made on behalf of the env (e.g. token requests),
+// it is set by the transport package, so the requests use TLS and proxy settings of the env
+var HTTPClient = func(e *Env) (*http.Client, error) {
+	return http.DefaultClient, nil
+}
+
+// cachedToken is an access token cached on disk
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Token returns access token and the time it has to be refreshed at (zero time if the token doesn't expire),
+// tokens are cached on disk until they are about to expire, so subsequent runs don't request new ones,
+// refresh requests a new token bypassing the cache (e.g. when the cached one is rejected)
+func (o *OAuth2) Token(c *http.Client, refresh bool) (string, time.Time, error) {
+	key := strings.Join([]string{o.TokenURL, o.ClientID, strings.Join(o.Scopes, " ")}, "\x00")
+
+	cached := cachedToken{}
+	if found, _ := cache.Load("oauth2", key, &cached); found && !refresh && cached.Token != "" {
+		return cached.Token, cached.Expires, nil
+	}
+
+	t, err := o.requestToken(c)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// token without expiration is requested again only when it is rejected
+	if t.ExpiresIn <= 0 {
+		return t.AccessToken, time.Time{}, nil
+	}
+
+	// token is considered expired a bit earlier to not be rejected in the middle of a query
+	ttl := time.Duration(t.ExpiresIn)*time.Second - 30*time.Second
+	if ttl <= 0 {
+		ttl = time.Duration(t.ExpiresIn) * time.Second / 2
+	}
+
+	cached = cachedToken{Token: t.AccessToken, Expires: time.Now().Add(ttl)}
+	if err := cache.Store("oauth2", key, cached, ttl); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache oauth2 token: %s\n", err)
+	}
+
+	return cached.Token, cached.Expires, nil
+}
+
+func (o *OAuth2) requestToken(c *http.Client) (*oauth2Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oauth2Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get oauth2 token: got unexpected http code=%d, body='%s'", res.StatusCode, string(body))
+	}
+
+	t := oauth2Token{}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+
+	if t.TokenType != "" && !strings.EqualFold(t.TokenType, "bearer") {
+		return nil, fmt.Errorf("token_type='%s' is not supported, only bearer tokens are", t.TokenType)
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,139 @@
+package config_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"elastiq/cache"
+	"elastiq/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv(cache.EnvCacheDir, dir)
+	defer os.Unsetenv(cache.EnvCacheDir)
+
+	requests := int32(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "elastiq" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		require.Equal(t, "logs:read logs:search", r.PostForm.Get("scope"))
+
+		n := atomic.AddInt32(&requests, 1)
+		expiresIn := 3600
+		if r.URL.Query().Get("short") != "" {
+			expiresIn = 1
+		}
+
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	defer srv.Close()
+
+	readEnv := func(secret string, query ...string) (*config.Env, error) {
+		cfg := config.Config{}
+		_, err := toml.Decode(fmt.Sprintf(`
+[env.dev]
+endpoints = ["http://localhost:9200"]
+[env.dev.authorization.oauth2]
+token_url     = "%s"
+client_id     = "elastiq"
+client_secret = "%s"
+scopes        = ["logs:read", "logs:search"]
+`, srv.URL+strings.Join(query, ""), secret), &cfg)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Validate())
+
+		return cfg.GetEnv("dev")
+	}
+
+	e, err := readEnv("secret")
+	require.NoError(t, err)
+
+	header, err := e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-1", header)
+
+	// the next invocation reuses cached token
+	e, err = readEnv("secret")
+	require.NoError(t, err)
+
+	header, err = e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-1", header)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	os.RemoveAll(dir)
+
+	e, err = readEnv("wrong")
+	require.NoError(t, err)
+
+	_, err = e.Authorization.Header["Authorization"].GetValue()
+	require.Error(t, err)
+
+	e, err = readEnv("secret")
+	require.NoError(t, err)
+
+	header, err = e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-2", header)
+
+	// rejected token is requested again bypassing the cache
+	require.True(t, e.RefreshAuthorization())
+	header, err = e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-3", header)
+
+	// token is requested again when it expires
+	e, err = readEnv("secret", "?short=1")
+	require.NoError(t, err)
+
+	header, err = e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-4", header)
+
+	time.Sleep(time.Second)
+	header, err = e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token-5", header)
+}
+
+func TestBearer(t *testing.T) {
+	os.Setenv("ELASTIQ_TEST_TOKEN", "token")
+	defer os.Unsetenv("ELASTIQ_TEST_TOKEN")
+
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.dev]
+endpoints = ["http://localhost:9200"]
+[env.dev.authorization.bearer]
+token = "${env:ELASTIQ_TEST_TOKEN}"
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	e, err := cfg.GetEnv("dev")
+	require.NoError(t, err)
+
+	header, err := e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token", header)
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SavedQuery is a named search runnable as "elastiq q @name",
+// settings specified on the command line override the saved ones, filters are added to the saved ones
+type SavedQuery struct {
+	Filters []string               `toml:"filters"`
+	Time    string                 `toml:"time"`
+	Index   string                 `toml:"index"`
+	Order   string                 `toml:"order"`
+	Limit   int                    `toml:"limit"`
+	Output  string                 `toml:"output"`
+	Params  map[string]*QueryParam `toml:"params"`
+}
+
+// QueryParam describes a {{placeholder}} of a saved query.
+// Placeholders not described in params are required,
+// filters using optional params without value are skipped
+type QueryParam struct {
+	Required bool        `toml:"required"`
+	List     bool        `toml:"list"`
+	D        interface{} `toml:"default"`
+	Default  []string    `toml:"-"`
+}
+
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// listFilterRegexp matches filters a list param can be used in: "key = {{param}}" and "key in {{param}}"
+var listFilterRegexp = regexp.MustCompile(`^\s*(.+?)\s*(=|in|IN)\s*\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}\s*$`)
+
+func (c *Config) GetQuery(name string) (*SavedQuery, error) {
+	if q, ok := c.Queries[name]; ok {
+		return q, nil
+	}
+
+	if len(c.Queries) == 0 {
+		return nil, fmt.Errorf("saved query='%s' not found, there are no saved queries in config", name)
+	}
+
+	names := make([]string, 0, len(c.Queries))
+	for k := range c.Queries {
+		names = append(names, "@"+k)
+	}
+	sort.Strings(names)
+
+	return nil, fmt.Errorf("saved query='%s' not found, available queries are [%s]", name, strings.Join(names, ", "))
+}
+
+func (q *SavedQuery) check(k string, c *Config) []error {
+	problems := []error{}
+
+	if q.Limit < 0 {
+		problems = append(problems, fmt.Errorf("query='%s' has negative limit", k))
+	}
+
+	if _, ok := c.Outputs[q.Output]; q.Output != "" && !ok {
+		problems = append(problems, fmt.Errorf("query='%s' uses unknown output='%s'", k, q.Output))
+	}
+
+	names := make([]string, 0, len(q.Params))
+	for name := range q.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := q.Params[name]
+		switch d := p.D.(type) {
+		case nil:
+		case string:
+			p.Default = []string{d}
+		case []interface{}:
+			p.Default = []string{}
+			for _, v := range d {
+				p.Default = append(p.Default, fmt.Sprint(v))
+			}
+		default:
+			p.Default = []string{fmt.Sprint(d)}
+		}
+
+		if len(p.Default) > 1 && !p.List {
+			problems = append(problems, fmt.Errorf("query='%s' has multiple default values of param='%s', which is not a list", k, name))
+		}
+	}
+
+	return problems
+}
+
+// Render returns the query with placeholders replaced by params,
+// every missing required param is reported at once
+func (q *SavedQuery) Render(params map[string][]string) (*SavedQuery, error) {
+	used := map[string]bool{}
+	for _, s := range append([]string{q.Time, q.Index}, q.Filters...) {
+		for _, m := range placeholderRegexp.FindAllStringSubmatch(s, -1) {
+			used[m[1]] = true
+		}
+	}
+
+	unknown := []string{}
+	for name := range params {
+		if _, ok := q.Params[name]; !ok && !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown params: %s", strings.Join(unknown, ", "))
+	}
+
+	values := map[string][]string{}
+	missing := []string{}
+	for name := range used {
+		p := q.Params[name]
+		if p == nil {
+			p = &QueryParam{Required: true}
+		}
+
+		v, ok := params[name]
+		if !ok {
+			v = p.Default
+		} else if p.List {
+			// list values can be passed either as a few params or separated with commas
+			split := []string{}
+			for _, vv := range v {
+				split = append(split, strings.Split(vv, ",")...)
+			}
+			v = split
+		}
+
+		if len(v) == 0 {
+			if p.Required {
+				missing = append(missing, name)
+			}
+			continue
+		}
+
+		if len(v) > 1 && !p.List {
+			return nil, fmt.Errorf("param='%s' is not a list, but got multiple values: %s", name, strings.Join(v, ", "))
+		}
+
+		values[name] = v
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required params: %s", strings.Join(missing, ", "))
+	}
+
+	render := func(s string) (string, bool) {
+		ok := true
+		s = placeholderRegexp.ReplaceAllStringFunc(s, func(ph string) string {
+			v, found := values[placeholderRegexp.FindStringSubmatch(ph)[1]]
+			if !found {
+				ok = false
+				return ""
+			}
+
+			return strings.Join(v, ",")
+		})
+
+		return s, ok
+	}
+
+	r := *q
+	r.Time, _ = render(q.Time)
+	r.Index, _ = render(q.Index)
+	r.Filters = []string{}
+	for _, f := range q.Filters {
+		if m := listFilterRegexp.FindStringSubmatch(f); m != nil {
+			if p := q.Params[m[3]]; p != nil && p.List {
+				v, ok := values[m[3]]
+				if !ok {
+					continue
+				}
+
+				quoted := make([]string, 0, len(v))
+				for _, vv := range v {
+					qv, err := quoteParam(vv)
+					if err != nil {
+						return nil, fmt.Errorf("param='%s': %w", m[3], err)
+					}
+					quoted = append(quoted, qv)
+				}
+
+				r.Filters = append(r.Filters, fmt.Sprintf("%s in %s", m[1], strings.Join(quoted, " ")))
+				continue
+			}
+		}
+
+		for _, m := range placeholderRegexp.FindAllStringSubmatch(f, -1) {
+			if p := q.Params[m[1]]; p != nil && p.List {
+				return nil, fmt.Errorf("list param='%s' can be used only as 'key = {{%s}}' or 'key in {{%s}}' filter", m[1], m[1], m[1])
+			}
+		}
+
+		// filters with optional params which are not set are skipped
+		if rendered, ok := render(f); ok {
+			r.Filters = append(r.Filters, rendered)
+		}
+	}
+
+	return &r, nil
+}
+
+// quoteParam quotes value of a list param, so it's parsed by filter as a single value.
+// Filter values are only stripped of quotes, so the value is wrapped into quotes it doesn't contain,
+// backslashes and newlines are kept as they are only by backticks
+func quoteParam(v string) (string, error) {
+	escaped := strings.ContainsAny(v, "\\\n")
+	switch {
+	case !escaped && !strings.Contains(v, "'"):
+		return "'" + v + "'", nil
+	case !strings.Contains(v, "`"):
+		return "`" + v + "`", nil
+	case !escaped && !strings.Contains(v, `"`):
+		return `"` + v + `"`, nil
+	}
+
+	return "", fmt.Errorf("value='%s' can't be quoted, it contains all of quotes", v)
+}
@@ -0,0 +1,221 @@
+package config_test
+
+import (
+	"testing"
+
+	"elastiq/config"
+	"elastiq/query"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetQuery(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.prod]
+endpoints = ["http://localhost:9200"]
+
+[query.payment-errors]
+filters = ["app = payment", "level = error"]
+time    = "-1h"
+limit   = 100
+
+[query.slow-requests]
+filters = ["duration > 1000"]
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	q, err := cfg.GetQuery("payment-errors")
+	require.NoError(t, err)
+	require.Equal(t, &config.SavedQuery{
+		Filters: []string{"app = payment", "level = error"},
+		Time:    "-1h",
+		Limit:   100,
+	}, q)
+
+	_, err = cfg.GetQuery("unknown")
+	require.EqualError(t, err, "saved query='unknown' not found, available queries are [@payment-errors, @slow-requests]")
+
+	_, err = (&config.Config{}).GetQuery("unknown")
+	require.EqualError(t, err, "saved query='unknown' not found, there are no saved queries in config")
+}
+
+func TestSavedQueryCheck(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.prod]
+endpoints = ["http://localhost:9200"]
+
+[query.a]
+limit = -1
+
+[query.b]
+output = "missing"
+`, &cfg)
+	require.NoError(t, err)
+
+	problems := []string{}
+	for _, err := range cfg.Check() {
+		problems = append(problems, err.Error())
+	}
+
+	require.Equal(t, []string{
+		"query='a' has negative limit",
+		"query='b' uses unknown output='missing'",
+	}, problems)
+}
+
+func TestSavedQueryRender(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.prod]
+endpoints = ["http://localhost:9200"]
+
+[query.by-request]
+filters = ["request_id = {{id}}", "level = {{level}}", "app in {{app}}", "host = {{host}}"]
+time    = "{{since}}"
+index   = "logs-{{region}}-*"
+
+[query.by-request.params.id]
+required = true
+[query.by-request.params.level]
+default = "error"
+[query.by-request.params.app]
+list    = true
+default = ["payment", "billing"]
+[query.by-request.params.host]
+[query.by-request.params.since]
+default = "-1h"
+[query.by-request.params.region]
+default = "eu"
+
+[query.bad-list]
+filters = ["message = 'user {{users}}'"]
+[query.bad-list.params.users]
+list = true
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	tests := []struct {
+		name    string
+		query   string
+		params  map[string][]string
+		filters []string
+		time    string
+		index   string
+		err     string
+	}{
+		{
+			name:    "defaults",
+			query:   "by-request",
+			params:  map[string][]string{"id": {"abc123"}},
+			filters: []string{"request_id = abc123", "level = error", "app in 'payment' 'billing'"},
+			time:    "-1h",
+			index:   "logs-eu-*",
+		},
+		{
+			name:  "all params",
+			query: "by-request",
+			params: map[string][]string{
+				"id":     {"abc123"},
+				"level":  {"warn"},
+				"app":    {"a,b", "c"},
+				"host":   {"host-1"},
+				"since":  {"-24h"},
+				"region": {"us"},
+			},
+			filters: []string{"request_id = abc123", "level = warn", "app in 'a' 'b' 'c'", "host = host-1"},
+			time:    "-24h",
+			index:   "logs-us-*",
+		},
+		{
+			name:    "list values with quotes",
+			query:   "by-request",
+			params:  map[string][]string{"id": {"abc123"}, "app": {"it's", `say "hi"`, `C:\temp`}},
+			filters: []string{"request_id = abc123", "level = error", "app in `it's` 'say \"hi\"' `C:\\temp`"},
+			time:    "-1h",
+			index:   "logs-eu-*",
+		},
+		{
+			name:   "list value with all of quotes",
+			query:  "by-request",
+			params: map[string][]string{"id": {"abc123"}, "app": {"'\"`"}},
+			err:    "param='app': value=''\"`' can't be quoted, it contains all of quotes",
+		},
+		{
+			name:   "missing required",
+			query:  "by-request",
+			params: map[string][]string{},
+			err:    "missing required params: id",
+		},
+		{
+			name:   "unknown param",
+			query:  "by-request",
+			params: map[string][]string{"id": {"abc123"}, "ids": {"abc123"}},
+			err:    "unknown params: ids",
+		},
+		{
+			name:   "multiple values of not a list",
+			query:  "by-request",
+			params: map[string][]string{"id": {"a", "b"}},
+			err:    "param='id' is not a list, but got multiple values: a, b",
+		},
+		{
+			name:   "list param inside value",
+			query:  "bad-list",
+			params: map[string][]string{"users": {"a"}},
+			err:    "list param='users' can be used only as 'key = {{users}}' or 'key in {{users}}' filter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := cfg.GetQuery(tt.query)
+			require.NoError(t, err)
+
+			r, err := q.Render(tt.params)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.filters, r.Filters)
+			require.Equal(t, tt.time, r.Time)
+			require.Equal(t, tt.index, r.Index)
+		})
+	}
+}
+
+func TestSavedQueryRenderListValues(t *testing.T) {
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.prod]
+endpoints = ["http://localhost:9200"]
+
+[query.by-app]
+filters = ["app in {{app}}"]
+
+[query.by-app.params.app]
+list = true
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	q, err := cfg.GetQuery("by-app")
+	require.NoError(t, err)
+
+	// every value is parsed back by filter as it was passed
+	values := []string{"plain", "it's", `say "hi"`, `C:\temp`, "with space", "it's `quoted`", "`a` \"b\""}
+	r, err := q.Render(map[string][]string{"app": values})
+	require.NoError(t, err)
+	require.Len(t, r.Filters, 1)
+
+	f, err := query.ParseFilter(r.Filters[0], query.TimeFilterSettings{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, query.IN, f.Operation)
+	require.Equal(t, values, f.Value)
+}
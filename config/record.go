@@ -0,0 +1,41 @@
+package config
+
+// RecordFields names fields of records which are common for all sources,
+// so records of envs with different sources can be merged into one timeline
+type RecordFields struct {
+	Timestamp string `toml:"timestamp"`
+	Message   string `toml:"message"`
+	Service   string `toml:"service"`
+}
+
+// GetRecordFields returns common fields configured for the env,
+// fields which are not configured default to the usual ones of the env source
+func (e *Env) GetRecordFields() RecordFields {
+	r := RecordFields{
+		Timestamp: "@timestamp",
+		Message:   "message",
+		Service:   "service",
+	}
+
+	if e.Source == SourceDataDog {
+		r.Timestamp = "timestamp"
+	}
+
+	if e.Record == nil {
+		return r
+	}
+
+	if e.Record.Timestamp != "" {
+		r.Timestamp = e.Record.Timestamp
+	}
+
+	if e.Record.Message != "" {
+		r.Message = e.Record.Message
+	}
+
+	if e.Record.Service != "" {
+		r.Service = e.Record.Service
+	}
+
+	return r
+}
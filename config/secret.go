@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EnvKeyringFile points to a JSON file with "service/account": "secret" pairs,
+// it replaces the OS keyring (useful for tests and systems without Secret Service)
+const EnvKeyringFile = "ELASTIQ_KEYRING_FILE"
+
+var secretRefRegexp = regexp.MustCompile(`\$\{(env|file|keyring):([^}]+)\}`)
+
+// literalPrefix marks a value taken as is, e.g. a password starting with "file:"
+const literalPrefix = "literal:"
+
+// ResolveSecret replaces references to secrets in the value:
+// "${env:NAME}" and "${file:path}" anywhere in the string,
+// "file:path" and "keyring:service/account" as the whole value,
+// nothing is replaced in a value prefixed with "literal:"
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, literalPrefix):
+		return strings.TrimPrefix(value, literalPrefix), nil
+
+	case strings.HasPrefix(value, "file:"):
+		return readSecretFile(strings.TrimPrefix(value, "file:"))
+
+	case strings.HasPrefix(value, "keyring:"):
+		return readKeyring(strings.TrimPrefix(value, "keyring:"))
+	}
+
+	var err error
+	result := secretRefRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+		m := secretRefRegexp.FindStringSubmatch(ref)
+
+		var v string
+		var e error
+		switch m[1] {
+		case "env":
+			var ok bool
+			v, ok = os.LookupEnv(m[2])
+			if !ok {
+				e = fmt.Errorf("environment variable='%s' is not set", m[2])
+			}
+		case "file":
+			v, e = readSecretFile(m[2])
+		case "keyring":
+			v, e = readKeyring(m[2])
+		}
+
+		if e != nil && err == nil {
+			err = e
+		}
+
+		return v
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// hasSecretReference tells whether resolving v would read a secret
+func hasSecretReference(v string) bool {
+	if strings.HasPrefix(v, literalPrefix) {
+		return false
+	}
+
+	return strings.HasPrefix(v, "file:") || strings.HasPrefix(v, "keyring:") || secretRefRegexp.MatchString(v)
+}
+
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home dir: %w", err)
+	}
+
+	return path.Join(home, p[1:]), nil
+}
+
+func readSecretFile(p string) (string, error) {
+	p, err := expandHome(p)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret from file='%s': %w", p, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readKeyring(ref string) (string, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("keyring reference='%s' has to be in form service/account", ref)
+	}
+
+	if file := os.Getenv(EnvKeyringFile); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read keyring file='%s': %w", file, err)
+		}
+
+		secrets := map[string]string{}
+		if err := json.Unmarshal(data, &secrets); err != nil {
+			return "", fmt.Errorf("failed to parse keyring file='%s': %w", file, err)
+		}
+
+		v, ok := secrets[ref]
+		if !ok {
+			return "", fmt.Errorf("secret='%s' not found in keyring file='%s'", ref, file)
+		}
+
+		return v, nil
+	}
+
+	// Secret Service is accessed via secret-tool from libsecret
+	v, err := runCommand([]string{"secret-tool", "lookup", "service", parts[0], "account", parts[1]}, 10*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret='%s' from keyring: %w", ref, err)
+	}
+
+	return v, nil
+}
+
+func resolveSecrets(values ...*string) error {
+	for _, v := range values {
+		r, err := ResolveSecret(*v)
+		if err != nil {
+			return err
+		}
+
+		*v = r
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elastiq-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	secretFile := path.Join(dir, "secret")
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("file secret\n"), 0600))
+
+	keyringFile := path.Join(dir, "keyring.json")
+	require.NoError(t, ioutil.WriteFile(keyringFile, []byte(`{"elastiq/prod": "keyring secret"}`), 0600))
+
+	os.Setenv("ELASTIQ_TEST_SECRET", "env secret")
+	defer os.Unsetenv("ELASTIQ_TEST_SECRET")
+
+	os.Setenv(config.EnvKeyringFile, keyringFile)
+	defer os.Unsetenv(config.EnvKeyringFile)
+
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		err    bool
+	}{
+		{name: "plain value", input: "plain", output: "plain"},
+		{name: "env reference", input: "${env:ELASTIQ_TEST_SECRET}", output: "env secret"},
+		{name: "embedded env reference", input: "user:${env:ELASTIQ_TEST_SECRET}", output: "user:env secret"},
+		{name: "missing env", input: "${env:ELASTIQ_TEST_MISSING}", err: true},
+		{name: "file reference", input: "file:" + secretFile, output: "file secret"},
+		{name: "embedded file reference", input: "Bearer ${file:" + secretFile + "}", output: "Bearer file secret"},
+		{name: "missing file", input: "file:" + path.Join(dir, "missing"), err: true},
+		{name: "keyring reference", input: "keyring:elastiq/prod", output: "keyring secret"},
+		{name: "missing keyring secret", input: "keyring:elastiq/stage", err: true},
+		{name: "invalid keyring reference", input: "keyring:elastiq", err: true},
+		{name: "literal value", input: "literal:file:secret", output: "file:secret"},
+		{name: "literal reference", input: "literal:${env:ELASTIQ_TEST_SECRET}", output: "${env:ELASTIQ_TEST_SECRET}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := config.ResolveSecret(tt.input)
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, v)
+			}
+		})
+	}
+}
+
+func TestGetEnvResolvesOnlyUsedEnv(t *testing.T) {
+	os.Setenv("ELASTIQ_TEST_PASSWORD", "password")
+	defer os.Unsetenv("ELASTIQ_TEST_PASSWORD")
+
+	cfg := config.Config{}
+	_, err := toml.Decode(`
+[env.dev]
+endpoints = ["http://localhost:9200"]
+[env.dev.authorization.basic]
+user     = "user"
+password = "${env:ELASTIQ_TEST_PASSWORD}"
+
+[env.prod]
+endpoints = ["http://localhost:9201"]
+[env.prod.authorization.basic]
+user     = "user"
+password = "${env:ELASTIQ_TEST_MISSING}"
+`, &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	e, err := cfg.GetEnv("dev")
+	require.NoError(t, err)
+
+	header, err := e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	require.Equal(t, "Basic dXNlcjpwYXNzd29yZA==", header)
+
+	_, err = cfg.GetEnv("prod")
+	require.Error(t, err)
+}
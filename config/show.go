@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const secretMask = "********"
+
+// secretKeys are config keys holding secrets, header values are secrets as well
+var secretKeys = map[string]bool{
+	"password":          true,
+	"api_key":           true,
+	"dd_api_key":        true,
+	"dd_personal_key":   true,
+	"client_secret":     true,
+	"token":             true,
+	"secret_access_key": true,
+	"session_token":     true,
+}
+
+// WriteMasked writes the config as TOML with secrets masked,
+// references to secrets (env, file or keyring) are written as is, since they don't disclose anything,
+// plaintext around them is masked
+func (c *Config) WriteMasked(w io.Writer) error {
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(c); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	if _, err := toml.Decode(buf.String(), &raw); err != nil {
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	maskTable(raw, false)
+
+	if err := toml.NewEncoder(w).Encode(raw); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	return nil
+}
+
+// maskTable masks secrets and removes empty values, so only specified settings are shown
+func maskTable(t map[string]interface{}, header bool) {
+	for k, v := range t {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			maskTable(vv, header || k == "header")
+			if len(vv) == 0 {
+				delete(t, k)
+			}
+
+		case []interface{}:
+			if len(vv) == 0 {
+				delete(t, k)
+			}
+
+		case string:
+			switch {
+			case vv == "":
+				delete(t, k)
+			case secretKeys[k] || header && k == "value":
+				t[k] = maskSecret(vv)
+			}
+
+		case bool:
+			if !vv {
+				delete(t, k)
+			}
+
+		case int64:
+			if vv == 0 {
+				delete(t, k)
+			}
+		}
+	}
+}
+
+// isSecretReference tells whether v references a secret (env, file or keyring) as the whole value
+func isSecretReference(v string) bool {
+	if strings.HasPrefix(v, literalPrefix) {
+		return false
+	}
+
+	if strings.HasPrefix(v, "file:") || strings.HasPrefix(v, "keyring:") {
+		return true
+	}
+
+	loc := secretRefRegexp.FindStringIndex(v)
+	return loc != nil && loc[0] == 0 && loc[1] == len(v)
+}
+
+// maskSecret masks plaintext parts of secret value v keeping references to secrets,
+// e.g. "Bearer ${env:TOKEN}" is shown as "********${env:TOKEN}"
+func maskSecret(v string) string {
+	if isSecretReference(v) {
+		return v
+	}
+
+	if strings.HasPrefix(v, literalPrefix) {
+		return secretMask
+	}
+
+	result := strings.Builder{}
+	last := 0
+	for _, loc := range secretRefRegexp.FindAllStringIndex(v, -1) {
+		if loc[0] > last {
+			result.WriteString(secretMask)
+		}
+
+		result.WriteString(v[loc[0]:loc[1]])
+		last = loc[1]
+	}
+
+	if last < len(v) {
+		result.WriteString(secretMask)
+	}
+
+	return result.String()
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultTraceDepth = 3
+
+// Trace configures correlation fields followed by trace command
+type Trace struct {
+	// Fields are searched for known ids, values of found records become new ids
+	Fields []string `toml:"fields"`
+	// Decode lists fields holding encoded json or http request (e.g. headers),
+	// correlation fields inside them are searched as phrases in the encoded field
+	Decode []string `toml:"decode"`
+	// Depth limits how many times new ids are followed
+	Depth int `toml:"depth"`
+}
+
+// GetTrace returns trace settings with defaults for ones not configured
+func (c *Config) GetTrace() *Trace {
+	t := Trace{}
+	if c.Trace != nil {
+		t = *c.Trace
+	}
+
+	if len(t.Fields) == 0 {
+		t.Fields = []string{"request_id", "trace_id"}
+	}
+
+	if t.Depth == 0 {
+		t.Depth = defaultTraceDepth
+	}
+
+	return &t
+}
+
+// Split returns encoded field the correlation field is inside of and the path inside it,
+// encoded is empty for fields which are not inside of decoded ones
+func (t *Trace) Split(field string) (encoded, path string) {
+	for _, d := range t.Decode {
+		if strings.HasPrefix(field, d+".") {
+			return d, strings.TrimPrefix(field, d+".")
+		}
+	}
+
+	return "", field
+}
+
+func (t *Trace) check() []error {
+	problems := []error{}
+
+	if t.Depth < 0 {
+		problems = append(problems, fmt.Errorf("trace has negative depth"))
+	}
+
+	for _, d := range t.Decode {
+		used := false
+		for _, f := range t.Fields {
+			if strings.HasPrefix(f, d+".") {
+				used = true
+			}
+		}
+
+		if !used {
+			problems = append(problems, fmt.Errorf("trace decodes field='%s', but no correlation field is inside of it", d))
+		}
+	}
+
+	return problems
+}
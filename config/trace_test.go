@@ -0,0 +1,50 @@
+package config_test
+
+import (
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTrace(t *testing.T) {
+	cfg := &config.Config{}
+	require.Equal(t, &config.Trace{Fields: []string{"request_id", "trace_id"}, Depth: 3}, cfg.GetTrace())
+
+	cfg.Trace = &config.Trace{
+		Fields: []string{"request_id", "span_id", "http.headers.x-request-id"},
+		Decode: []string{"http.headers"},
+		Depth:  5,
+	}
+	trace := cfg.GetTrace()
+	require.Equal(t, cfg.Trace, trace)
+
+	encoded, path := trace.Split("http.headers.x-request-id")
+	require.Equal(t, "http.headers", encoded)
+	require.Equal(t, "x-request-id", path)
+
+	encoded, path = trace.Split("span_id")
+	require.Equal(t, "", encoded)
+	require.Equal(t, "span_id", path)
+}
+
+func TestCheckTrace(t *testing.T) {
+	cfg := &config.Config{
+		Trace: &config.Trace{
+			Fields: []string{"request_id"},
+			Decode: []string{"http.headers"},
+			Depth:  -1,
+		},
+	}
+
+	problems := []string{}
+	for _, err := range cfg.Check() {
+		problems = append(problems, err.Error())
+	}
+
+	require.Equal(t, []string{
+		"trace has negative depth",
+		"trace decodes field='http.headers', but no correlation field is inside of it",
+	}, problems)
+}
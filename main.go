@@ -15,6 +15,13 @@ func main() {
 	}
 
 	commands.AddQueryCommand(rootCmd)
+	commands.AddGetCommand(rootCmd)
+	commands.AddContextCommand(rootCmd)
+	commands.AddTraceCommand(rootCmd)
+	commands.AddExportCommand(rootCmd)
+	commands.AddIndicesCommand(rootCmd)
+	commands.AddFieldsCommand(rootCmd)
+	commands.AddConfigCommand(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"elastiq/config"
 )
@@ -26,6 +29,62 @@ func JSONOutput(records []map[string]interface{}) (io.Reader, error) {
 	return bytes.NewReader(buf.Bytes()), nil
 }
 
+// FormatOutput formats records according to the output format
+func FormatOutput(records []map[string]interface{}, o *config.Output) (io.Reader, error) {
+	if o.Format == "json" {
+		return JSONOutput(records)
+	}
+
+	return nil, fmt.Errorf("format='%s' is not implemented", o.Format)
+}
+
+// TableOutput prints records as aligned columns, column names are used as a header
+func TableOutput(records []map[string]interface{}, columns []string) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = strings.ToUpper(c)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, r := range records {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			v, ok := Lookup(r, c)
+			switch {
+			case !ok || v == nil:
+				row[i] = "-"
+			case isComposite(v):
+				j, err := json.Marshal(v)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal value of column='%s': %w", c, err)
+				}
+				row[i] = string(j)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write table: %w", err)
+	}
+
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+func isComposite(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}, []string:
+		return true
+	}
+
+	return false
+}
+
 func decodeHTTPRequest(str string) interface{} {
 	result := map[string]interface{}{}
 
@@ -137,17 +196,86 @@ func RecursiveDecode(i interface{}, dmap map[string]bool) interface{} {
 	return i
 }
 
+// Lookup finds value by dotted path like "kubernetes.labels.app",
+// keys containing dots themselves are handled as well
+func Lookup(record map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := record[path]; ok {
+		return v, true
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+
+		sub, ok := record[path[:i]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if v, ok := Lookup(sub, path[i+1:]); ok {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// setPath sets value by dotted path creating nested objects
+func setPath(record map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := record[p].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			record[p] = sub
+		}
+		record = sub
+	}
+
+	record[parts[len(parts)-1]] = v
+}
+
+// deletePath deletes value found by dotted path the same way Lookup finds it
+func deletePath(record map[string]interface{}, path string) bool {
+	if _, ok := record[path]; ok {
+		delete(record, path)
+		return true
+	}
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+
+		sub, ok := record[path[:i]].(map[string]interface{})
+		if ok && deletePath(sub, path[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ApplyOutputFilters keeps only or excludes fields of the output, fields can be specified by dotted paths
 func ApplyOutputFilters(record map[string]interface{}, o *config.Output) map[string]interface{} {
 	if o.Only != nil {
 		final := map[string]interface{}{}
 		for _, k := range o.Only {
+			if _, ok := record[k]; !ok {
+				if v, ok := Lookup(record, k); ok {
+					setPath(final, k, v)
+					continue
+				}
+			}
+
 			final[k] = record[k]
 		}
 
 		record = final
 	} else if o.Exclude != nil {
 		for _, k := range o.Exclude {
-			delete(record, k)
+			deletePath(record, k)
 		}
 	}
 
@@ -159,3 +287,78 @@ func ApplyOutputFilters(record map[string]interface{}, o *config.Output) map[str
 
 	return record
 }
+
+// Compare compares values records are ordered by: numbers numerically, timestamps chronologically,
+// anything else as strings, missing values go after any other
+func Compare(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	if ta, err := time.Parse(time.RFC3339Nano, sa); err == nil {
+		if tb, err := time.Parse(time.RFC3339Nano, sb); err == nil {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(sa, sb)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch vv := v.(type) {
+	case float64:
+		return vv, true
+	case int:
+		return float64(vv), true
+	case int64:
+		return float64(vv), true
+	case json.Number:
+		f, err := vv.Float64()
+		return f, err == nil
+	case string:
+		// integers are kept as strings by jvalue to not lose precision
+		f, err := strconv.ParseFloat(vv, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// ParseTimestamp parses timestamp of a record given either as RFC3339 string or as epoch milliseconds
+func ParseTimestamp(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, true
+		}
+	}
+
+	if ms, ok := toFloat(v); ok {
+		return time.Unix(0, int64(ms)*int64(time.Millisecond)), true
+	}
+
+	return time.Time{}, false
+}
@@ -1,8 +1,11 @@
 package output_test
 
 import (
+	"io/ioutil"
 	"testing"
+	"time"
 
+	"elastiq/config"
 	ot "elastiq/output"
 
 	"github.com/stretchr/testify/require"
@@ -244,3 +247,162 @@ Host: somehost
 `
 
 var http7 = "GET /api/v1/method HTTP/1.1\r\nHost: somehost\r\n\r\n"
+
+func TestLookup(t *testing.T) {
+	record := map[string]interface{}{
+		"level": "info",
+		"kubernetes": map[string]interface{}{
+			"pod": map[string]interface{}{
+				"name": "pod-1",
+			},
+			"labels.app": "app-1",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		output interface{}
+		found  bool
+	}{
+		{name: "top level key", path: "level", output: "info", found: true},
+		{name: "nested key", path: "kubernetes.pod.name", output: "pod-1", found: true},
+		{name: "key with dots", path: "kubernetes.labels.app", output: "app-1", found: true},
+		{name: "missing key", path: "kubernetes.pod.namespace", found: false},
+		{name: "path through scalar", path: "level.name", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, found := ot.Lookup(record, tt.path)
+			require.Equal(t, tt.found, found)
+			require.Equal(t, tt.output, v)
+		})
+	}
+}
+
+func TestTableOutput(t *testing.T) {
+	records := []map[string]interface{}{
+		{"name": "index-1", "docs": 10, "tags": []string{"a", "b"}},
+		{"name": "long-index-name", "docs": 1000},
+	}
+
+	r, err := ot.TableOutput(records, []string{"name", "docs", "tags"})
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	expected := "" +
+		"NAME             DOCS  TAGS\n" +
+		"index-1          10    [\"a\",\"b\"]\n" +
+		"long-index-name  1000  -\n"
+	require.Equal(t, expected, string(b))
+}
+
+func TestApplyOutputFilters(t *testing.T) {
+	record := func() map[string]interface{} {
+		return map[string]interface{}{
+			"level":   "info",
+			"message": "hello",
+			"kubernetes": map[string]interface{}{
+				"pod": map[string]interface{}{
+					"name": "pod-1",
+				},
+				"labels.app": "app-1",
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		output *config.Output
+		result map[string]interface{}
+	}{
+		{
+			name:   "only top level keys",
+			output: &config.Output{Only: []string{"level", "missing"}},
+			result: map[string]interface{}{"level": "info", "missing": nil},
+		},
+		{
+			name:   "only dotted paths",
+			output: &config.Output{Only: []string{"message", "kubernetes.pod.name", "kubernetes.labels.app"}},
+			result: map[string]interface{}{
+				"message": "hello",
+				"kubernetes": map[string]interface{}{
+					"pod": map[string]interface{}{
+						"name": "pod-1",
+					},
+					"labels": map[string]interface{}{
+						"app": "app-1",
+					},
+				},
+			},
+		},
+		{
+			name:   "exclude dotted paths",
+			output: &config.Output{Exclude: []string{"message", "kubernetes.pod.name", "kubernetes.labels.app", "missing.key"}},
+			result: map[string]interface{}{
+				"level": "info",
+				"kubernetes": map[string]interface{}{
+					"pod": map[string]interface{}{},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.result, ot.ApplyOutputFilters(record(), tt.output))
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      interface{}
+		b      interface{}
+		output int
+	}{
+		{name: "numbers", a: 2.5, b: 10, output: -1},
+		{name: "numeric strings", a: "10", b: "9", output: 1},
+		{name: "timestamps in different zones", a: "2021-01-01T10:00:00+03:00", b: "2021-01-01T08:00:00Z", output: -1},
+		{name: "strings", a: "b", b: "a", output: 1},
+		{name: "equal", a: "a", b: "a", output: 0},
+		{name: "missing goes last", a: nil, b: "a", output: 1},
+		{name: "both missing", a: nil, b: nil, output: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.output, ot.Compare(tt.a, tt.b))
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  interface{}
+		output string
+		ok     bool
+	}{
+		{name: "rfc3339", input: "2021-07-14T12:00:01+02:00", output: "2021-07-14T10:00:01Z", ok: true},
+		{name: "fractional seconds", input: "2021-07-14T10:00:01.123Z", output: "2021-07-14T10:00:01.123Z", ok: true},
+		{name: "epoch millis", input: float64(1626256801123), output: "2021-07-14T10:00:01.123Z", ok: true},
+		{name: "epoch millis kept as string", input: "1626256801000", output: "2021-07-14T10:00:01Z", ok: true},
+		{name: "unknown format", input: "yesterday", ok: false},
+		{name: "missing", input: nil, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, ok := ot.ParseTimestamp(tt.input)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.Equal(t, tt.output, ts.UTC().Format(time.RFC3339Nano))
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package query
+
+// ExpandValueAliases replaces aliased values of filters with the values they stand for,
+// valueAliases maps field to aliases of its values.
+// Equality becomes "in" filter, inequality becomes inequality for every value
+func ExpandValueAliases(filters []*Filter, valueAliases map[string]map[string][]string) []*Filter {
+	result := make([]*Filter, 0, len(filters))
+	for _, f := range filters {
+		aliases := valueAliases[f.Key]
+		if len(aliases) == 0 {
+			result = append(result, f)
+			continue
+		}
+
+		switch f.Operation {
+		case EQ, TEQ:
+			if values, ok := aliases[f.Value[0]]; ok {
+				f = &Filter{Key: f.Key, Operation: IN, Value: values}
+			}
+			result = append(result, f)
+
+		case NEQ:
+			values, ok := aliases[f.Value[0]]
+			if !ok {
+				result = append(result, f)
+				continue
+			}
+
+			for _, v := range values {
+				result = append(result, &Filter{Key: f.Key, Operation: NEQ, Value: []string{v}})
+			}
+
+		case IN:
+			values := []string{}
+			for _, v := range f.Value {
+				if vv, ok := aliases[v]; ok {
+					values = append(values, vv...)
+				} else {
+					values = append(values, v)
+				}
+			}
+			result = append(result, &Filter{Key: f.Key, Operation: IN, Value: values})
+
+		default:
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,64 @@
+package query_test
+
+import (
+	"testing"
+
+	q "elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandValueAliases(t *testing.T) {
+	aliases := map[string]map[string][]string{
+		"level": {
+			"err": {"error", "fatal", "critical"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		input  []*q.Filter
+		output []*q.Filter
+	}{
+		{
+			name:   "equals becomes in",
+			input:  []*q.Filter{{Key: "level", Operation: q.EQ, Value: []string{"err"}}},
+			output: []*q.Filter{{Key: "level", Operation: q.IN, Value: []string{"error", "fatal", "critical"}}},
+		},
+		{
+			name:   "strict equals becomes in",
+			input:  []*q.Filter{{Key: "level", Operation: q.TEQ, Value: []string{"err"}}},
+			output: []*q.Filter{{Key: "level", Operation: q.IN, Value: []string{"error", "fatal", "critical"}}},
+		},
+		{
+			name:  "not equals for every value",
+			input: []*q.Filter{{Key: "level", Operation: q.NEQ, Value: []string{"err"}}},
+			output: []*q.Filter{
+				{Key: "level", Operation: q.NEQ, Value: []string{"error"}},
+				{Key: "level", Operation: q.NEQ, Value: []string{"fatal"}},
+				{Key: "level", Operation: q.NEQ, Value: []string{"critical"}},
+			},
+		},
+		{
+			name:   "in with alias",
+			input:  []*q.Filter{{Key: "level", Operation: q.IN, Value: []string{"warn", "err"}}},
+			output: []*q.Filter{{Key: "level", Operation: q.IN, Value: []string{"warn", "error", "fatal", "critical"}}},
+		},
+		{
+			name:   "value without alias",
+			input:  []*q.Filter{{Key: "level", Operation: q.EQ, Value: []string{"info"}}},
+			output: []*q.Filter{{Key: "level", Operation: q.EQ, Value: []string{"info"}}},
+		},
+		{
+			name:   "field without aliases",
+			input:  []*q.Filter{{Key: "message", Operation: q.EQ, Value: []string{"err"}}},
+			output: []*q.Filter{{Key: "message", Operation: q.EQ, Value: []string{"err"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.output, q.ExpandValueAliases(tt.input, aliases))
+		})
+	}
+}
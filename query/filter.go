@@ -31,6 +31,9 @@ type Filter struct {
 	Key       string
 	Value     []string
 	Operation FilterOperation
+
+	// Format is date format of range values, format of the field mapping is used if it is empty
+	Format string
 }
 
 type TimeFilterSettings struct {
@@ -0,0 +1,221 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mapping contains mapped types for every field of an index
+type Mapping map[string][]string
+
+type fieldClass string
+
+const (
+	classText    fieldClass = "text"
+	classKeyword fieldClass = "keyword"
+	classNumeric fieldClass = "numeric"
+	classDate    fieldClass = "date"
+	classOther   fieldClass = "other"
+)
+
+func classOf(t string) fieldClass {
+	switch t {
+	case "text", "match_only_text", "search_as_you_type":
+		return classText
+	case "keyword", "constant_keyword", "wildcard", "ip", "boolean", "version":
+		return classKeyword
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		return classNumeric
+	case "date", "date_nanos":
+		return classDate
+	}
+
+	return classOther
+}
+
+// class returns the class of the field or classOther if the field is mapped with types of different classes
+func (m Mapping) class(key string) fieldClass {
+	types := m[key]
+	if len(types) == 0 {
+		return classOther
+	}
+
+	c := classOf(types[0])
+	for _, t := range types[1:] {
+		if classOf(t) != c {
+			return classOther
+		}
+	}
+
+	return c
+}
+
+func (m Mapping) hasSubfields(key string) bool {
+	for k := range m {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ApplyMapping validates filter keys against mapping and picks operations suitable for mapped types,
+// unknown fields and questionable filters are reported as warnings, impossible ones as errors
+func ApplyMapping(filters []*Filter, m Mapping) ([]string, error) {
+	warnings := []string{}
+
+	for _, f := range filters {
+		if strings.HasPrefix(f.Key, "_") {
+			continue
+		}
+
+		if _, ok := m[f.Key]; !ok {
+			if f.Operation == EX && m.hasSubfields(f.Key) {
+				continue
+			}
+
+			w := fmt.Sprintf("field='%s' is not mapped", f.Key)
+			if s := m.Suggest(f.Key); len(s) > 0 {
+				w += fmt.Sprintf(", did you mean %s?", strings.Join(s, ", "))
+			}
+			warnings = append(warnings, w)
+			continue
+		}
+
+		keyword := f.Key + ".keyword"
+		hasKeyword := m.class(keyword) == classKeyword
+
+		switch m.class(f.Key) {
+		case classText:
+			switch f.Operation {
+			case TEQ:
+				if !hasKeyword {
+					warnings = append(warnings, fmt.Sprintf("field='%s' is text, strict equality will likely match nothing", f.Key))
+					continue
+				}
+				f.Key = keyword
+
+			case GT, GTE, LT, LTE, BT:
+				if !hasKeyword {
+					return warnings, fmt.Errorf("field='%s' is text and has no keyword subfield, range filters are not possible", f.Key)
+				}
+				warnings = append(warnings, fmt.Sprintf("field='%s' is text, range is applied lexicographically to '%s'", f.Key, keyword))
+				f.Key = keyword
+			}
+
+		case classKeyword:
+			switch f.Operation {
+			case EQ:
+				f.Operation = TEQ
+
+			case GT, GTE, LT, LTE, BT:
+				for _, v := range f.Value {
+					if _, err := strconv.ParseFloat(v, 64); err == nil {
+						warnings = append(warnings, fmt.Sprintf("field='%s' is keyword, range is applied lexicographically, not numerically", f.Key))
+						break
+					}
+				}
+			}
+
+		case classNumeric:
+			switch f.Operation {
+			case EQ:
+				f.Operation = TEQ
+				fallthrough
+
+			case TEQ, NEQ, GT, GTE, LT, LTE, BT, IN:
+				for _, v := range f.Value {
+					if _, err := strconv.ParseFloat(v, 64); err != nil {
+						return warnings, fmt.Errorf("field='%s' is numeric (%s), value='%s' is not a number", f.Key, strings.Join(m[f.Key], ","), v)
+					}
+				}
+			}
+
+		case classDate:
+			if f.Operation == EQ {
+				f.Operation = TEQ
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// Suggest returns mapped fields similar to the given key
+func (m Mapping) Suggest(key string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	maxDistance := len(key) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	candidates := []candidate{}
+	for name := range m {
+		d := levenshtein(key, name)
+
+		// "status_code" is likely to be "http.status_code"
+		if strings.HasSuffix(name, "."+key) {
+			d = 1
+		}
+
+		if d <= maxDistance {
+			candidates = append(candidates, candidate{name, d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	result := []string{}
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		result = append(result, candidates[i].name)
+	}
+
+	return result
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,115 @@
+package query_test
+
+import (
+	"testing"
+
+	q "elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMapping(t *testing.T) {
+	mapping := q.Mapping{
+		"message":             {"text"},
+		"message.keyword":     {"keyword"},
+		"description":         {"text"},
+		"level":               {"keyword"},
+		"http.status_code":    {"long"},
+		"@timestamp":          {"date"},
+		"kubernetes.pod.name": {"keyword"},
+	}
+
+	tests := []struct {
+		name     string
+		input    q.Filter
+		output   q.Filter
+		warnings int
+		err      bool
+	}{
+		{
+			name:   "equals on keyword becomes term",
+			input:  q.Filter{Key: "level", Operation: q.EQ, Value: []string{"error"}},
+			output: q.Filter{Key: "level", Operation: q.TEQ, Value: []string{"error"}},
+		},
+		{
+			name:   "equals on text stays match phrase",
+			input:  q.Filter{Key: "message", Operation: q.EQ, Value: []string{"some text"}},
+			output: q.Filter{Key: "message", Operation: q.EQ, Value: []string{"some text"}},
+		},
+		{
+			name:   "strict equals on text uses keyword subfield",
+			input:  q.Filter{Key: "message", Operation: q.TEQ, Value: []string{"some text"}},
+			output: q.Filter{Key: "message.keyword", Operation: q.TEQ, Value: []string{"some text"}},
+		},
+		{
+			name:   "equals on numeric becomes term",
+			input:  q.Filter{Key: "http.status_code", Operation: q.EQ, Value: []string{"500"}},
+			output: q.Filter{Key: "http.status_code", Operation: q.TEQ, Value: []string{"500"}},
+		},
+		{
+			name:  "not a number for numeric field",
+			input: q.Filter{Key: "http.status_code", Operation: q.GT, Value: []string{"abc"}},
+			err:   true,
+		},
+		{
+			name:     "range on text uses keyword subfield",
+			input:    q.Filter{Key: "message", Operation: q.GT, Value: []string{"a"}},
+			output:   q.Filter{Key: "message.keyword", Operation: q.GT, Value: []string{"a"}},
+			warnings: 1,
+		},
+		{
+			name:  "range on text without keyword subfield",
+			input: q.Filter{Key: "description", Operation: q.GT, Value: []string{"a"}},
+			err:   true,
+		},
+		{
+			name:     "numeric range on keyword",
+			input:    q.Filter{Key: "level", Operation: q.BT, Value: []string{"1", "5"}},
+			output:   q.Filter{Key: "level", Operation: q.BT, Value: []string{"1", "5"}},
+			warnings: 1,
+		},
+		{
+			name:     "unknown field",
+			input:    q.Filter{Key: "status_code", Operation: q.EQ, Value: []string{"500"}},
+			output:   q.Filter{Key: "status_code", Operation: q.EQ, Value: []string{"500"}},
+			warnings: 1,
+		},
+		{
+			name:   "exists on object",
+			input:  q.Filter{Key: "kubernetes.pod", Operation: q.EX, Value: []string{}},
+			output: q.Filter{Key: "kubernetes.pod", Operation: q.EX, Value: []string{}},
+		},
+		{
+			name:   "metadata field",
+			input:  q.Filter{Key: "_id", Operation: q.TEQ, Value: []string{"qwe"}},
+			output: q.Filter{Key: "_id", Operation: q.TEQ, Value: []string{"qwe"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.input
+			warnings, err := q.ApplyMapping([]*q.Filter{&f}, mapping)
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, f)
+				require.Len(t, warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestMapping_Suggest(t *testing.T) {
+	mapping := q.Mapping{
+		"level":            {"keyword"},
+		"http.status_code": {"long"},
+		"message":          {"text"},
+	}
+
+	require.Equal(t, []string{"http.status_code"}, mapping.Suggest("status_code"))
+	require.Equal(t, []string{"level"}, mapping.Suggest("levle"))
+	require.Equal(t, []string{"message"}, mapping.Suggest("mesage"))
+	require.Empty(t, mapping.Suggest("kubernetes"))
+}
@@ -3,18 +3,27 @@ package query
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type Order struct {
 	By        string
 	Ascending bool
+	// Missing places records without the field either "_first" or "_last" (default)
+	Missing string
+	// UnmappedType is used for indices which do not have the field mapped
+	UnmappedType string
+	// NumericType converts sort values of indices mapping the field differently (e.g. date and date_nanos) to one type
+	NumericType string
 }
 
 type StartFrom *[]interface{}
 
+// GetOrder parses order like key/direction/missing/unmapped=type,
+// everything but key is optional, direction defaults to descending
 func GetOrder(order string) (*Order, error) {
 	parts := strings.Split(order, "/")
-	if len(parts) > 2 {
+	if len(parts) > 4 {
 		return nil, fmt.Errorf("order='%s' splited in too many parts", order)
 	}
 
@@ -42,15 +51,52 @@ func GetOrder(order string) (*Order, error) {
 		return nil, fmt.Errorf("got order='%s', unknown order direction='%s'", order, o)
 	}
 
+	for _, option := range parts[2:] {
+		switch {
+		case option == "first" || option == "_first":
+			res.Missing = "_first"
+		case option == "last" || option == "_last":
+			res.Missing = "_last"
+		case strings.HasPrefix(option, "unmapped="):
+			res.UnmappedType = strings.TrimPrefix(option, "unmapped=")
+		default:
+			return nil, fmt.Errorf("got order='%s', unknown order option='%s', expected first, last or unmapped=type", order, option)
+		}
+	}
+
 	return &res, nil
 }
 
+// GetOrders parses comma separated orders like level/asc,@timestamp/desc,
+// records are ordered by the next key when values of previous ones are equal
+func GetOrders(orders string) ([]*Order, error) {
+	result := []*Order{}
+	for _, order := range strings.Split(orders, ",") {
+		o, err := GetOrder(strings.TrimSpace(order))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, o)
+	}
+
+	return result, nil
+}
+
 type Query struct {
 	Filters []*Filter
-	Order   *Order
+	Order   []*Order
 	Limit   int
 	Index   string
 	Output  string
+
+	// Includes and Excludes limit fields of fetched records, all fields are fetched if they are empty
+	Includes []string
+	Excludes []string
+
+	// Fields and DocValueFields are fetched besides records themselves
+	Fields         []string
+	DocValueFields []string
 }
 
 type Options struct {
@@ -59,4 +105,16 @@ type Options struct {
 	Raw       bool
 	AsCurl    bool
 	FromStdin bool
+
+	// KeepSource makes search keep found records as they are along with ones prepared for output
+	KeepSource bool
+}
+
+// Anchor describes a record to search context around,
+// it is either a document id or a point in time
+type Anchor struct {
+	ID   string
+	Time *time.Time
+	Same []string
+	Size int
 }
@@ -0,0 +1,65 @@
+package query_test
+
+import (
+	"testing"
+
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrders(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output []*query.Order
+		err    string
+	}{
+		{
+			name:   "key only",
+			input:  "@timestamp",
+			output: []*query.Order{{By: "@timestamp"}},
+		},
+		{
+			name:  "several keys",
+			input: "level/asc, @timestamp/desc",
+			output: []*query.Order{
+				{By: "level", Ascending: true},
+				{By: "@timestamp"},
+			},
+		},
+		{
+			name:   "missing and unmapped type",
+			input:  "duration/asc/last/unmapped=long",
+			output: []*query.Order{{By: "duration", Ascending: true, Missing: "_last", UnmappedType: "long"}},
+		},
+		{
+			name:  "unknown option",
+			input: "level/asc/middle",
+			err:   "got order='level/asc/middle', unknown order option='middle', expected first, last or unmapped=type",
+		},
+		{
+			name:  "unknown direction",
+			input: "level/up",
+			err:   "got order='level/up', unknown order direction='up'",
+		},
+		{
+			name:  "empty key",
+			input: "level/asc,",
+			err:   "got order='', order key can not be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := query.GetOrders(tt.input)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.output, orders)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package sigv4
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"elastiq/config"
+)
+
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+type Provider interface {
+	Retrieve() (Credentials, error)
+}
+
+type StaticProvider Credentials
+
+func (p StaticProvider) Retrieve() (Credentials, error) {
+	return Credentials(p), nil
+}
+
+type EnvProvider struct{}
+
+func (EnvProvider) Retrieve() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+
+	return creds, nil
+}
+
+// ProfileProvider reads credentials from shared credentials file (~/.aws/credentials by default)
+type ProfileProvider struct {
+	File    string
+	Profile string
+}
+
+func (p ProfileProvider) Retrieve() (Credentials, error) {
+	file := p.File
+	if file == "" {
+		file = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	}
+
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to get home dir: %w", err)
+		}
+		file = path.Join(home, ".aws", "credentials")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to open aws credentials file: %w", err)
+	}
+	defer f.Close()
+
+	creds := Credentials{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		if section != p.Profile {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		v := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = v
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = v
+		case "aws_session_token":
+			creds.SessionToken = v
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Credentials{}, fmt.Errorf("failed to read aws credentials file: %w", err)
+	}
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("profile='%s' not found in aws credentials file='%s'", p.Profile, file)
+	}
+
+	return creds, nil
+}
+
+type assumeRoleResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleResult"`
+}
+
+// AssumeRoleProvider obtains temporary credentials of the role via STS using base credentials,
+// they are reused until they are about to expire
+type AssumeRoleProvider struct {
+	Base       Provider
+	RoleARN    string
+	ExternalID string
+	Region     string
+	Endpoint   string
+	Now        func() time.Time
+
+	mu      sync.Mutex
+	creds   Credentials
+	expires time.Time
+}
+
+func (p *AssumeRoleProvider) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+
+	return time.Now()
+}
+
+func (p *AssumeRoleProvider) Retrieve() (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.creds.AccessKeyID != "" && p.now().Before(p.expires.Add(-time.Minute)) {
+		return p.creds, nil
+	}
+
+	ep := p.Endpoint
+	if ep == "" {
+		ep = fmt.Sprintf("https://sts.%s.amazonaws.com", p.Region)
+	}
+
+	params := url.Values{}
+	params.Set("Action", "AssumeRole")
+	params.Set("Version", "2011-06-15")
+	params.Set("RoleArn", p.RoleARN)
+	params.Set("RoleSessionName", "elastiq")
+	if p.ExternalID != "" {
+		params.Set("ExternalId", p.ExternalID)
+	}
+
+	req, err := http.NewRequest("GET", ep+"/?"+params.Encode(), nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to create sts request: %w", err)
+	}
+
+	signer := Signer{Region: p.Region, Service: "sts", Credentials: p.Base, Now: p.Now}
+	if err := signer.Sign(req); err != nil {
+		return Credentials{}, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("sts request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read sts response: %w", err)
+	}
+
+	if res.StatusCode != 200 {
+		return Credentials{}, fmt.Errorf("failed to assume role='%s': got unexpected http code=%d, body='%s'", p.RoleARN, res.StatusCode, string(body))
+	}
+
+	resp := assumeRoleResponse{}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse sts response: %w", err)
+	}
+
+	c := resp.Result.Credentials
+	p.creds = Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken}
+	p.expires = c.Expiration
+
+	return p.creds, nil
+}
+
+// NewSigner creates signer according to env config
+func NewSigner(a *config.AWS) *Signer {
+	var provider Provider
+	switch {
+	case a.AccessKeyID != "":
+		provider = StaticProvider{AccessKeyID: a.AccessKeyID, SecretAccessKey: a.SecretAccessKey, SessionToken: a.SessionToken}
+	case a.Profile != "":
+		provider = ProfileProvider{Profile: a.Profile}
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		provider = EnvProvider{}
+	default:
+		profile := os.Getenv("AWS_PROFILE")
+		if profile == "" {
+			profile = "default"
+		}
+		provider = ProfileProvider{Profile: profile}
+	}
+
+	if a.RoleARN != "" {
+		provider = &AssumeRoleProvider{
+			Base:       provider,
+			RoleARN:    a.RoleARN,
+			ExternalID: a.ExternalID,
+			Region:     a.Region,
+			Endpoint:   a.STSEndpoint,
+		}
+	}
+
+	return &Signer{Region: a.Region, Service: a.Service, Credentials: provider}
+}
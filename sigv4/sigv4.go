@@ -0,0 +1,194 @@
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+type Signer struct {
+	Region      string
+	Service     string
+	Credentials Provider
+	// Now allows to fix the clock in tests
+	Now func() time.Time
+}
+
+// Sign adds X-Amz-Date, X-Amz-Security-Token and Authorization headers to the request,
+// request body is read via GetBody, so the request can be signed again on retry
+func (s *Signer) Sign(req *http.Request) error {
+	creds, err := s.Credentials.Retrieve()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve aws credentials: %w", err)
+	}
+
+	body := []byte{}
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return fmt.Errorf("failed to get request body: %w", err)
+		}
+
+		body, err = ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+
+	req.Header.Set("X-Amz-Date", t.Format(timeFormat))
+	req.Header.Del("X-Amz-Security-Token")
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	payloadHash := hashHex(body)
+
+	// serverless collections require payload hash header
+	if s.Service == "aoss" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	req.Header.Set("Authorization", authorization(req, payloadHash, s.Region, s.Service, creds, t))
+	return nil
+}
+
+func authorization(req *http.Request, payloadHash, region, service string, creds Credentials, t time.Time) string {
+	headers := signedHeaders(req)
+	scope := strings.Join([]string{t.Format(dateFormat), region, service, "aws4_request"}, "/")
+
+	return fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, strings.Join(headers, ";"),
+		signature(req, headers, payloadHash, region, service, creds.SecretAccessKey, t),
+	)
+}
+
+func signature(req *http.Request, headers []string, payloadHash, region, service, secret string, t time.Time) string {
+	scope := strings.Join([]string{t.Format(dateFormat), region, service, "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		algorithm,
+		t.Format(timeFormat),
+		scope,
+		hashHex([]byte(canonicalRequest(req, headers, payloadHash))),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secret), t.Format(dateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signedHeaders lists headers to sign: host, content-type and x-amz-* ones
+func signedHeaders(req *http.Request) []string {
+	headers := []string{"host"}
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers = append(headers, lk)
+		}
+	}
+
+	sort.Strings(headers)
+	return headers
+}
+
+func canonicalRequest(req *http.Request, headers []string, payloadHash string) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	canonicalHeaders := ""
+	for _, h := range headers {
+		v := host
+		if h != "host" {
+			v = strings.Join(req.Header.Values(h), ",")
+		}
+		canonicalHeaders += h + ":" + strings.Join(strings.Fields(v), " ") + "\n"
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		// every service except S3 expects path to be escaped twice
+		escape(path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		strings.Join(headers, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		values := append([]string{}, q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// escape encodes everything except unreserved characters as RFC 3986 demands
+func escape(s string, escapeSlash bool) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
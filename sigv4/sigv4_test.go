@@ -0,0 +1,182 @@
+package sigv4
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCreds = Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	testNow = func() time.Time {
+		return time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC)
+	}
+)
+
+// taken from AWS SigV4 test suite (get-vanilla)
+func TestSignVanilla(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+
+	s := Signer{Region: "us-east-1", Service: "service", Credentials: StaticProvider(testCreds), Now: testNow}
+	require.NoError(t, s.Sign(req))
+
+	require.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	require.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"),
+	)
+}
+
+var authRegexp = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/([^/]+)/aws4_request, SignedHeaders=([^,]+), Signature=([0-9a-f]{64})$`)
+
+// verifyingStub checks signatures of received requests as AWS would do
+func verifyingStub(t *testing.T, secrets map[string]string, now func() time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := authRegexp.FindStringSubmatch(r.Header.Get("Authorization"))
+		if m == nil {
+			http.Error(w, "missing or malformed authorization", http.StatusForbidden)
+			return
+		}
+
+		secret, ok := secrets[m[1]]
+		if !ok {
+			http.Error(w, "unknown access key", http.StatusForbidden)
+			return
+		}
+
+		ts, err := time.Parse(timeFormat, r.Header.Get("X-Amz-Date"))
+		if err != nil || !ts.Equal(now()) {
+			http.Error(w, "invalid date", http.StatusForbidden)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		expected := signature(r, strings.Split(m[5], ";"), hashHex(body), m[3], m[4], secret, ts)
+		if expected != m[6] {
+			http.Error(w, fmt.Sprintf("signature mismatch: expected %s", expected), http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(r.Header.Get("X-Amz-Security-Token")))
+	}))
+}
+
+func TestSignVerifiedByStub(t *testing.T) {
+	srv := verifyingStub(t, map[string]string{testCreds.AccessKeyID: testCreds.SecretAccessKey}, testNow)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		service string
+		creds   Credentials
+		code    int
+	}{
+		{name: "es", service: "es", creds: testCreds, code: 200},
+		{name: "serverless", service: "aoss", creds: testCreds, code: 200},
+		{name: "wrong secret", service: "es", creds: Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wrong"}, code: 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", srv.URL+"/logs-*,other/_search?pretty=true&size=10", bytes.NewReader([]byte(`{"size":1}`)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			s := Signer{Region: "eu-west-1", Service: tt.service, Credentials: StaticProvider(tt.creds), Now: testNow}
+			require.NoError(t, s.Sign(req))
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, tt.code, res.StatusCode)
+		})
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "AssumeRole", r.URL.Query().Get("Action"))
+		require.Equal(t, "arn:aws:iam::123456789012:role/reader", r.URL.Query().Get("RoleArn"))
+		require.Contains(t, r.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/20150830/eu-west-1/sts/aws4_request")
+
+		fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIATEMP</AccessKeyId>
+      <SecretAccessKey>temporary-secret</SecretAccessKey>
+      <SessionToken>session-token</SessionToken>
+      <Expiration>2015-08-30T13:36:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`)
+	}))
+	defer sts.Close()
+
+	es := verifyingStub(t, map[string]string{"ASIATEMP": "temporary-secret"}, testNow)
+	defer es.Close()
+
+	provider := &AssumeRoleProvider{
+		Base:     StaticProvider(testCreds),
+		RoleARN:  "arn:aws:iam::123456789012:role/reader",
+		Region:   "eu-west-1",
+		Endpoint: sts.URL,
+		Now:      testNow,
+	}
+
+	req, err := http.NewRequest("GET", es.URL+"/_cat/indices", nil)
+	require.NoError(t, err)
+
+	s := Signer{Region: "eu-west-1", Service: "es", Credentials: provider, Now: testNow}
+	require.NoError(t, s.Sign(req))
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, 200, res.StatusCode)
+	require.Equal(t, "session-token", string(body))
+}
+
+func TestProfileProvider(t *testing.T) {
+	f, err := ioutil.TempFile("", "aws-credentials")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[prod]
+# comment
+aws_access_key_id     = AKIDPROD
+aws_secret_access_key = prod-secret
+aws_session_token     = prod-token
+`)
+	require.NoError(t, err)
+	f.Close()
+
+	creds, err := ProfileProvider{File: f.Name(), Profile: "prod"}.Retrieve()
+	require.NoError(t, err)
+	require.Equal(t, Credentials{AccessKeyID: "AKIDPROD", SecretAccessKey: "prod-secret", SessionToken: "prod-token"}, creds)
+
+	_, err = ProfileProvider{File: f.Name(), Profile: "stage"}.Retrieve()
+	require.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
@@ -16,55 +17,137 @@ import (
 	"elastiq/jvalue"
 	"elastiq/output"
 	"elastiq/query"
+	"elastiq/transport"
 )
 
 type ddclient struct {
 	config *config.Config
 }
 
+type event struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Attributes map[string]jvalue.JValue `json:"attributes"`
+
+		// reserved attributes of event
+		Timestamp string   `json:"timestamp"`
+		Message   string   `json:"message"`
+		Service   string   `json:"service"`
+		Host      string   `json:"host"`
+		Status    string   `json:"status"`
+		Tags      []string `json:"tags"`
+	} `json:"attributes"`
+}
+
 type response struct {
-	Data []struct {
-		Attributes struct {
-			Attributes map[string]jvalue.JValue `json:"attributes"`
-		} `json:"attributes"`
-	} `json:"data"`
+	Data  []event `json:"data"`
 	Links struct {
 		Next string `json:"next"`
 	} `json:"links"`
 }
 
-func (c *ddclient) Query(ctx context.Context, e *config.Env, q *query.Query, o query.Options) (io.Reader, error) {
-	total := q.Limit
+type eventResponse struct {
+	Data *event `json:"data"`
+}
 
+func (c *ddclient) Query(ctx context.Context, e *config.Env, q *query.Query, o query.Options) (io.Reader, error) {
 	output, err := c.config.GetOutput(e, q.Output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get output: %w", err)
 	}
 
+	if o.Recursive != nil {
+		output.Decode = config.FromStringList(*o.Recursive)
+	}
+
 	if o.FromStdin {
 		return applyOutputFromReader(os.Stdin, output)
 	}
 
-	ep := fmt.Sprintf("%s/api/v2/logs/events/search", e.GetEndpoint())
+	readers := []io.Reader{}
+	result, err := c.pages(ctx, e, q, o, func(resp *response) error {
+		reader, err := applyOutput(resp, output)
+		if err != nil {
+			return err
+		}
+
+		readers = append(readers, reader)
+		return nil
+	})
+
+	if err != nil || result != nil {
+		return result, err
+	}
+
+	return io.MultiReader(readers...), nil
+}
+
+func (c *ddclient) Search(ctx context.Context, e *config.Env, q *query.Query, o query.Options) ([]*client.Record, error) {
+	if o.FromStdin || o.Raw || o.AsCurl {
+		return nil, fmt.Errorf("--stdin, --raw and --curl are not supported by search")
+	}
+
+	out, err := c.config.GetOutput(e, q.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output: %w", err)
+	}
+
+	if o.Recursive != nil {
+		out.Decode = config.FromStringList(*o.Recursive)
+	}
+
+	records := []*client.Record{}
+	_, err = c.pages(ctx, e, q, o, func(resp *response) error {
+		for i := range resp.Data {
+			r := client.NewRecord(e, withReserved(&resp.Data[i]), q.Order)
+			r.ID = resp.Data[i].ID
+			if o.KeepSource {
+				r.Source = withReserved(&resp.Data[i])
+			}
+			r.Fields = output.ApplyOutputFilters(unwrapAttributes(&resp.Data[i]), out)
+			records = append(records, r)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// pages runs paged search calling page for every page of events,
+// reader is returned only for --curl and --raw options
+func (c *ddclient) pages(ctx context.Context, e *config.Env, q *query.Query, o query.Options, page func(resp *response) error) (io.Reader, error) {
+	total := q.Limit
+
+	// pages are requested from the session endpoint, which may change on failover
+	s := transport.NewSession(e)
 	iteration := 0
 	sf := query.StartFrom(nil)
 
-	readers := []io.Reader{}
-
 	for total > 0 && iteration < 100 {
 		iteration++
 
 		var req *http.Request
 		var body []byte
+		var err error
 
 		if sf != nil {
 			ssf := *sf
-			ep = fmt.Sprint(ssf[0])
+			ep, err := nextPage(s.Endpoint(), fmt.Sprint(ssf[0]))
+			if err != nil {
+				return nil, err
+			}
+
 			req, err = http.NewRequestWithContext(ctx, "GET", ep, nil)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create http request: %w", err)
 			}
 		} else {
+			ep := fmt.Sprintf("%s/api/v2/logs/events/search", s.Endpoint())
 			ddq, err := composeRequest(q, sf)
 			if err != nil {
 				return nil, fmt.Errorf("failed to compose request: %w", err)
@@ -75,32 +158,27 @@ func (c *ddclient) Query(ctx context.Context, e *config.Env, q *query.Query, o q
 				return nil, fmt.Errorf("failed to marsahl request: %w", err)
 			}
 
-			req, err = http.NewRequestWithContext(ctx, "POST", ep, bytes.NewReader(body))
+			// search request only reads data, so it is safe to retry
+			req, err = http.NewRequestWithContext(transport.Idempotent(ctx), "POST", ep, bytes.NewReader(body))
 			if err != nil {
 				return nil, fmt.Errorf("failed to create http request: %w", err)
 			}
 		}
 
-		req.Header.Add("content-type", "application/json")
-		req.Header.Add("DD-API-KEY", e.DDAPIKey)
-		req.Header.Add("DD-APPLICATION-KEY", e.DDAppKey)
+		addHeaders(req, e)
 
 		if o.AsCurl {
-			str := fmt.Sprintf("curl -d '%s'", string(body))
-			for k, v := range req.Header {
-				str += fmt.Sprintf(" -H '%s: %s'", k, v[0])
-			}
-			str += fmt.Sprintf(" '%s'\n", ep)
-			return strings.NewReader(str), nil
+			return asCurl(req, body), nil
 		}
 
-		res, err := http.DefaultClient.Do(req)
+		res, err := s.Do(req)
 		if err != nil {
 			return nil, fmt.Errorf("http request failed: %w", err)
 		}
 
 		if res.StatusCode != 200 {
 			errBody, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
 			return nil, fmt.Errorf("got unexpected http code=%d, body='%s'", res.StatusCode, string(errBody))
 		}
 
@@ -108,11 +186,8 @@ func (c *ddclient) Query(ctx context.Context, e *config.Env, q *query.Query, o q
 			return res.Body, nil
 		}
 
-		if o.Recursive != nil {
-			output.Decode = config.FromStringList(*o.Recursive)
-		}
-
 		resp, err := parseResponse(res.Body)
+		res.Body.Close()
 		if err != nil {
 			return nil, err
 		}
@@ -127,19 +202,76 @@ func (c *ddclient) Query(ctx context.Context, e *config.Env, q *query.Query, o q
 			sf = &next
 		}
 
-		reader, err := applyOutput(resp, output)
-		if err != nil {
+		if err := page(resp); err != nil {
 			return nil, err
 		}
 
-		readers = append(readers, reader)
-
-		if total >= q.Limit {
+		// no next link means the last page was read
+		if total >= q.Limit || resp.Links.Next == "" {
 			break
 		}
 	}
 
-	return io.MultiReader(readers...), nil
+	return nil, nil
+}
+
+// nextPage returns url of the next page link on endpoint,
+// links point to the host of the previous page, which may be down by now
+func nextPage(endpoint, next string) (string, error) {
+	u, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse next page link='%s': %w", next, err)
+	}
+
+	return endpoint + u.RequestURI(), nil
+}
+
+func (c *ddclient) Get(ctx context.Context, e *config.Env, id string, q *query.Query, o query.Options) (io.Reader, error) {
+	output, err := c.config.GetOutput(e, q.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output: %w", err)
+	}
+
+	if o.FromStdin {
+		return applyEventOutputFromReader(os.Stdin, output)
+	}
+
+	s := transport.NewSession(e)
+	ep := fmt.Sprintf("%s/api/v2/logs/events/%s", s.Endpoint(), url.PathEscape(id))
+	req, err := http.NewRequestWithContext(ctx, "GET", ep, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+
+	addHeaders(req, e)
+
+	if o.AsCurl {
+		return asCurl(req, nil), nil
+	}
+
+	res, err := s.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("event with id='%s' not found", id)
+	}
+
+	if res.StatusCode != 200 {
+		errBody, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("got unexpected http code=%d, body='%s'", res.StatusCode, string(errBody))
+	}
+
+	if o.Raw {
+		return res.Body, nil
+	}
+
+	if o.Recursive != nil {
+		output.Decode = config.FromStringList(*o.Recursive)
+	}
+
+	return applyEventOutputFromReader(res.Body, output)
 }
 
 func NewClient(cfg *config.Config) client.Client {
@@ -162,6 +294,46 @@ func parseResponse(r io.Reader) (*response, error) {
 	return &resp, nil
 }
 
+func addHeaders(req *http.Request, e *config.Env) {
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add("DD-API-KEY", e.DDAPIKey)
+	req.Header.Add("DD-APPLICATION-KEY", e.DDAppKey)
+}
+
+func asCurl(req *http.Request, body []byte) io.Reader {
+	str := "curl"
+	if body != nil {
+		str += fmt.Sprintf(" -d '%s'", string(body))
+	}
+
+	for k, v := range req.Header {
+		str += fmt.Sprintf(" -H '%s: %s'", k, v[0])
+	}
+	str += fmt.Sprintf(" '%s'\n", req.URL.String())
+
+	return strings.NewReader(str)
+}
+
+func applyEventOutputFromReader(r io.Reader, o *config.Output) (io.Reader, error) {
+	j, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read all: %w", err)
+	}
+
+	er := eventResponse{}
+
+	err = json.Unmarshal(j, &er)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if er.Data == nil {
+		return nil, fmt.Errorf("response contains no event")
+	}
+
+	return applyOutput(&response{Data: []event{*er.Data}}, o)
+}
+
 func applyOutputFromReader(r io.Reader, o *config.Output) (io.Reader, error) {
 	resp, err := parseResponse(r)
 	if err != nil {
@@ -171,19 +343,47 @@ func applyOutputFromReader(r io.Reader, o *config.Output) (io.Reader, error) {
 	return applyOutput(resp, o)
 }
 
-func applyOutput(resp *response, o *config.Output) (io.Reader, error) {
-	records := make([]map[string]interface{}, 0, len(resp.Data))
-	for _, v := range resp.Data {
-		r := make(map[string]interface{}, len(v.Attributes.Attributes))
-		for k, v := range v.Attributes.Attributes {
-			r[k] = v.Unwrap()
+func unwrapAttributes(v *event) map[string]interface{} {
+	r := make(map[string]interface{}, len(v.Attributes.Attributes))
+	for k, v := range v.Attributes.Attributes {
+		r[k] = v.Unwrap()
+	}
+
+	return r
+}
+
+// withReserved returns attributes of event along with its reserved attributes,
+// which take precedence over attributes with the same names
+func withReserved(v *event) map[string]interface{} {
+	r := unwrapAttributes(v)
+	for k, vv := range map[string]string{
+		"timestamp": v.Attributes.Timestamp,
+		"message":   v.Attributes.Message,
+		"service":   v.Attributes.Service,
+		"host":      v.Attributes.Host,
+		"status":    v.Attributes.Status,
+	} {
+		if vv != "" {
+			r[k] = vv
 		}
-		records = append(records, output.ApplyOutputFilters(r, o))
 	}
 
-	if o.Format == "json" {
-		return output.JSONOutput(records)
+	if len(v.Attributes.Tags) > 0 {
+		tags := make([]interface{}, 0, len(v.Attributes.Tags))
+		for _, t := range v.Attributes.Tags {
+			tags = append(tags, t)
+		}
+		r["tags"] = tags
+	}
+
+	return r
+}
+
+func applyOutput(resp *response, o *config.Output) (io.Reader, error) {
+	records := make([]map[string]interface{}, 0, len(resp.Data))
+	for i := range resp.Data {
+		records = append(records, output.ApplyOutputFilters(unwrapAttributes(&resp.Data[i]), o))
 	}
 
-	return nil, fmt.Errorf("format='%s' is not implemented", o.Format)
+	return output.FormatOutput(records, o)
 }
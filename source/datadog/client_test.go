@@ -0,0 +1,168 @@
+package datadog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"elastiq/config"
+	"elastiq/query"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestConfig(t *testing.T, endpoint string) (*config.Config, *config.Env) {
+	cfg := config.Config{}
+	_, err := toml.Decode(fmt.Sprintf(`
+[env.dd]
+endpoints       = ["%s"]
+source          = "datadog"
+dd_api_key      = "api"
+dd_personal_key = "app"
+`, endpoint), &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	e, err := cfg.GetEnv("dd")
+	require.NoError(t, err)
+
+	return &cfg, e
+}
+
+func TestSearchPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "api", r.Header.Get("DD-API-KEY"))
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v2/logs/events/search":
+			// next link points to the host the first page came from
+			fmt.Fprint(w, `{
+				"data": [{"id": "1", "attributes": {"timestamp": "2021-07-14T10:00:01Z", "message": "first", "attributes": {"n": "1"}}}],
+				"links": {"next": "https://dead.invalid/api/v2/logs/events?page%5Bcursor%5D=abc"}
+			}`)
+
+		case r.Method == "GET" && r.URL.Path == "/api/v2/logs/events":
+			require.Equal(t, "abc", r.URL.Query().Get("page[cursor]"))
+			fmt.Fprint(w, `{
+				"data": [{"id": "2", "attributes": {"timestamp": "2021-07-14T10:00:00Z", "message": "second", "attributes": {"n": "2"}}}],
+				"links": {}
+			}`)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg, e := readTestConfig(t, srv.URL)
+	c := NewClient(cfg).(*ddclient)
+
+	records, err := c.Search(context.Background(), e, &query.Query{Limit: 10}, query.Options{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "1", records[0].ID)
+	require.Equal(t, "second", records[1].Message)
+	require.Equal(t, map[string]interface{}{"n": "2"}, records[1].Fields)
+}
+
+func TestNextPage(t *testing.T) {
+	ep, err := nextPage("http://127.0.0.1:8080", "https://api.datadoghq.eu/api/v2/logs/events?page%5Bcursor%5D=abc")
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:8080/api/v2/logs/events?page%5Bcursor%5D=abc", ep)
+}
+
+func TestGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "GET", r.Method)
+		require.Equal(t, "api", r.Header.Get("DD-API-KEY"))
+		require.Equal(t, "app", r.Header.Get("DD-APPLICATION-KEY"))
+
+		switch r.URL.EscapedPath() {
+		case "/api/v2/logs/events/a%2F1":
+			fmt.Fprint(w, `{"data": {"id": "a/1", "attributes": {"timestamp": "2021-07-14T10:00:01Z", "message": "hi", "attributes": {"n": "1"}}}}`)
+
+		case "/api/v2/logs/events/empty":
+			fmt.Fprint(w, `{}`)
+
+		case "/api/v2/logs/events/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "oops")
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg, e := readTestConfig(t, srv.URL)
+	c := NewClient(cfg).(*ddclient)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		id     string
+		err    string
+		record map[string]interface{}
+	}{
+		{
+			name:   "found event",
+			id:     "a/1",
+			record: map[string]interface{}{"n": "1"},
+		},
+		{
+			name: "missing event",
+			id:   "b",
+			err:  "event with id='b' not found",
+		},
+		{
+			name: "response without event",
+			id:   "empty",
+			err:  "response contains no event",
+		},
+		{
+			name: "unexpected code",
+			id:   "broken",
+			err:  "got unexpected http code=500, body='oops'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := c.Get(ctx, e, tt.id, &query.Query{}, query.Options{})
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			record := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r).Decode(&record))
+			require.Equal(t, tt.record, record)
+		})
+	}
+
+	t.Run("raw event", func(t *testing.T) {
+		r, err := c.Get(ctx, e, "a/1", &query.Query{}, query.Options{Raw: true})
+		require.NoError(t, err)
+
+		event := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r).Decode(&event))
+		require.Equal(t, "a/1", event["data"].(map[string]interface{})["id"])
+	})
+
+	t.Run("as curl", func(t *testing.T) {
+		r, err := c.Get(ctx, e, "a/1", &query.Query{}, query.Options{AsCurl: true})
+		require.NoError(t, err)
+
+		curl, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Contains(t, string(curl), srv.URL+"/api/v2/logs/events/a%2F1")
+		require.Contains(t, string(curl), "-H 'Dd-Api-Key: api'")
+	})
+}
@@ -70,6 +70,13 @@ func composeRequest(q *query.Query, sf query.StartFrom) (*DataDogRequest, error)
 		case query.NEQ:
 			queryFilters = append(queryFilters, fmt.Sprintf("-%s%s", keyPart, ddEscapeFilter(f.Key, f.Value[0])))
 
+		case query.IN:
+			values := make([]string, 0, len(f.Value))
+			for _, v := range f.Value {
+				values = append(values, ddEscapeFilter(f.Key, v))
+			}
+			queryFilters = append(queryFilters, fmt.Sprintf("%s(%s)", keyPart, strings.Join(values, " OR ")))
+
 		case query.BT:
 			queryFilters = append(queryFilters, fmt.Sprintf("%s[%s TO %s]", keyPart, f.Value[0], f.Value[1]))
 
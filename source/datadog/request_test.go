@@ -114,7 +114,9 @@ func Test_composeRequest(t *testing.T) {
 				Value:     []string{"asd"},
 				Operation: query.IN,
 			},
-			err: true,
+			output: DataDogFilter{
+				Query: "qwe:(asd)",
+			},
 		},
 		{
 			name: "basic in with multiple values",
@@ -123,7 +125,9 @@ func Test_composeRequest(t *testing.T) {
 				Value:     []string{"asd", "zxc", "lkj"},
 				Operation: query.IN,
 			},
-			err: true,
+			output: DataDogFilter{
+				Query: "qwe:(asd OR zxc OR lkj)",
+			},
 		},
 		{
 			name: "basic exists",
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
@@ -16,6 +18,7 @@ import (
 	"elastiq/jvalue"
 	"elastiq/output"
 	"elastiq/query"
+	"elastiq/transport"
 )
 
 const maxRecordsPerRequest = 10000
@@ -27,77 +30,240 @@ type elasticlient struct {
 	config *config.Config
 }
 
+type hit struct {
+	Index  string                   `json:"_index"`
+	ID     string                   `json:"_id"`
+	Found  bool                     `json:"found"`
+	Source map[string]jvalue.JValue `json:"_source"`
+	Fields map[string]jvalue.JValue `json:"fields"`
+	Sort   query.StartFrom          `json:"sort"`
+}
+
+// UnmarshalJSON keeps sort values as json numbers, since longs (e.g. of date_nanos) don't fit float64
+func (h *hit) UnmarshalJSON(b []byte) error {
+	type plain hit
+	v := struct {
+		*plain
+		Sort json.RawMessage `json:"sort"`
+	}{plain: (*plain)(h)}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	h.Sort = nil
+	if len(v.Sort) == 0 || string(v.Sort) == "null" {
+		return nil
+	}
+
+	sort := []interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(v.Sort))
+	dec.UseNumber()
+	if err := dec.Decode(&sort); err != nil {
+		return err
+	}
+
+	h.Sort = &sort
+	return nil
+}
+
 type response struct {
 	Hits struct {
-		Hits []struct {
-			Source map[string]jvalue.JValue `json:"_source"`
-			Sort   query.StartFrom          `json:"sort"`
-		} `json:"hits"`
+		Hits  []hit     `json:"hits"`
+		Total totalHits `json:"total"`
 	} `json:"hits"`
+	PITID    string `json:"pit_id"`
+	ScrollID string `json:"_scroll_id"`
 }
 
-func (c *elasticlient) Query(ctx context.Context, e *config.Env, q *query.Query, o query.Options) (io.Reader, error) {
-	total := q.Limit
+// totalHits is number of found records, which is given as {"value": n} since elasticsearch 7 and as n before
+type totalHits int64
 
-	// maximum records per request from elasticsearch
-	if q.Limit > maxRecordsPerRequest {
-		q.Limit = maxRecordsPerRequest
-	}
+func (t *totalHits) UnmarshalJSON(b []byte) error {
+	v := struct {
+		Value int64 `json:"value"`
+	}{}
 
-	index := q.Index
-	if index == "" {
-		index = e.Index
+	if err := json.Unmarshal(b, &v); err == nil {
+		*t = totalHits(v.Value)
+		return nil
 	}
 
+	return json.Unmarshal(b, (*int64)(t))
+}
+
+func (c *elasticlient) Query(ctx context.Context, e *config.Env, q *query.Query, o query.Options) (io.Reader, error) {
 	output, err := c.config.GetOutput(e, q.Output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get output: %w", err)
 	}
 
+	if o.Recursive != nil {
+		output.Decode = config.FromStringList(*o.Recursive)
+	}
+
 	if o.FromStdin {
 		return applyOutputFromReader(os.Stdin, output)
 	}
 
-	if index == "" {
-		return nil, fmt.Errorf("neither index was specified, nor default index for env was found")
+	// raw and curl show the request and response as they are, without post processing
+	fq := q
+	if !o.Raw && !o.AsCurl {
+		fq = filterSource(q, output)
 	}
 
-	ep := fmt.Sprintf("%s/%s/_search?pretty=true", e.GetEndpoint(), index)
-	iteration := 0
-	sf := query.StartFrom(nil)
-
 	readers := []io.Reader{}
+	result, err := c.pages(ctx, e, fq, o, func(resp *response) error {
+		reader, err := applyOutput(resp, output)
+		if err != nil {
+			return err
+		}
 
-	for total > 0 && iteration < 100 {
-		iteration++
+		readers = append(readers, reader)
+		return nil
+	})
 
-		body, err := ComposeRequest(q, sf)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compose request: %w", err)
+	if err != nil || result != nil {
+		return result, err
+	}
+
+	return io.MultiReader(readers...), nil
+}
+
+func (c *elasticlient) Search(ctx context.Context, e *config.Env, q *query.Query, o query.Options) ([]*client.Record, error) {
+	if o.FromStdin || o.Raw || o.AsCurl {
+		return nil, fmt.Errorf("--stdin, --raw and --curl are not supported by search")
+	}
+
+	out, err := c.config.GetOutput(e, q.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output: %w", err)
+	}
+
+	if o.Recursive != nil {
+		out.Decode = config.FromStringList(*o.Recursive)
+	}
+
+	// fields records are ordered by and common fields have to be fetched regardless of output,
+	// trace keeping records as they are needs all of the fields
+	fq := q
+	if !o.KeepSource {
+		rf := e.GetRecordFields()
+		keep := []string{rf.Timestamp, rf.Message, rf.Service}
+		for _, order := range q.Order {
+			keep = append(keep, order.By)
 		}
+		fq = filterSource(q, out, keep...)
+	}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", ep, bytes.NewReader(body))
-		req.Header.Add("content-type", "application/json")
-		if e.Authorization != nil {
-			for k, v := range e.Authorization.Header {
-				req.Header.Add(k, v.GetValue())
+	records := []*client.Record{}
+	_, err = c.pages(ctx, e, fq, o, func(resp *response) error {
+		for i := range resp.Hits.Hits {
+			source := unwrapSource(&resp.Hits.Hits[i])
+			r := client.NewRecord(e, source, q.Order)
+			r.ID = resp.Hits.Hits[i].ID
+			if o.KeepSource {
+				r.Source = unwrapSource(&resp.Hits.Hits[i])
 			}
+			r.Fields = output.ApplyOutputFilters(source, out)
+			records = append(records, r)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// filterSource returns copy of query fetching only fields shown by output,
+// keep lists fields which have to be fetched anyway
+func filterSource(q *query.Query, o *config.Output, keep ...string) *query.Query {
+	qq := *q
+	qq.Fields = o.Fields
+	qq.DocValueFields = o.DocValueFields
+
+	// only takes precedence over exclude as it does in output
+	if len(o.Only) > 0 {
+		qq.Includes = append(append([]string{}, o.Only...), keep...)
+		return &qq
+	}
+
+	for _, ex := range o.Exclude {
+		kept := false
+		for _, k := range keep {
+			if k == ex || strings.HasPrefix(k, ex+".") {
+				kept = true
+			}
+		}
+
+		if !kept {
+			qq.Excludes = append(qq.Excludes, ex)
+		}
+	}
+
+	return &qq
+}
+
+// docSourceParams returns query string limiting _source returned by _doc API,
+// which can't return fields and docvalue_fields
+func docSourceParams(q *query.Query) string {
+	params := url.Values{}
+	if len(q.Includes) > 0 {
+		params.Set("_source_includes", strings.Join(q.Includes, ","))
+	}
+
+	if len(q.Excludes) > 0 {
+		params.Set("_source_excludes", strings.Join(q.Excludes, ","))
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+
+	return "?" + params.Encode()
+}
+
+// pages runs paged search calling page for every page of hits,
+// reader is returned only for --curl and --raw options
+func (c *elasticlient) pages(ctx context.Context, e *config.Env, q *query.Query, o query.Options, page func(resp *response) error) (io.Reader, error) {
+	index := q.Index
+	if index == "" {
+		index = e.Index
+	}
+
+	if index == "" {
+		return nil, fmt.Errorf("neither index was specified, nor default index for env was found")
+	}
+
+	// the same endpoint is used for all pages
+	s := newSession(ctx, e)
+
+	// a single request is enough when limit fits it, --curl and --raw show only the first page
+	if q.Limit <= maxRecordsPerRequest || o.AsCurl || o.Raw {
+		qq := *q
+		if qq.Limit > maxRecordsPerRequest {
+			qq.Limit = maxRecordsPerRequest
 		}
 
+		body, err := ComposeRequest(&qq, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create http request: %w", err)
+			return nil, fmt.Errorf("failed to compose request: %w", err)
+		}
+
+		req, err := newRequest(ctx, s, "POST", fmt.Sprintf("/%s/_search?pretty=true", index), body)
+		if err != nil {
+			return nil, err
 		}
 
 		if o.AsCurl {
-			str := fmt.Sprintf("curl -d '%s'", string(body))
-			for k, v := range req.Header {
-				str += fmt.Sprintf(" -H '%s: %s'", k, v[0])
-			}
-			str += fmt.Sprintf(" '%s'\n", ep)
-			return strings.NewReader(str), nil
+			return asCurl(req, body), nil
 		}
 
-		res, err := http.DefaultClient.Do(req)
+		res, err := s.Do(req)
 		if err != nil {
 			return nil, fmt.Errorf("http request failed: %w", err)
 		}
@@ -110,11 +276,38 @@ func (c *elasticlient) Query(ctx context.Context, e *config.Env, q *query.Query,
 			return res.Body, nil
 		}
 
-		if o.Recursive != nil {
-			output.Decode = config.FromStringList(*o.Recursive)
+		resp, err := parseResponse(res.Body)
+		if err != nil {
+			return nil, err
 		}
 
-		resp, err := parseResponse(res.Body)
+		return nil, page(resp)
+	}
+
+	pit, err := openPIT(ctx, s, index, keepAlive)
+	if errors.Is(err, errPITUnsupported) {
+		return nil, c.scroll(ctx, s, index, q, page)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// point in time is closed even when the query is interrupted
+	defer closePIT(context.Background(), s, pit)
+
+	total := q.Limit
+	sf := query.StartFrom(nil)
+	for total > 0 {
+		qq := *q
+		qq.Limit = total
+		if qq.Limit > maxRecordsPerRequest {
+			qq.Limit = maxRecordsPerRequest
+		}
+
+		resp, err := searchPIT(ctx, s, pit, func() ([]byte, error) {
+			return ComposePITRequest(&qq, sf, pit)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -126,19 +319,165 @@ func (c *elasticlient) Query(ctx context.Context, e *config.Env, q *query.Query,
 		total -= len(resp.Hits.Hits)
 		sf = resp.Hits.Hits[len(resp.Hits.Hits)-1].Sort
 
-		reader, err := applyOutput(resp, output)
-		if err != nil {
+		if err := page(resp); err != nil {
 			return nil, err
 		}
 
-		readers = append(readers, reader)
+		if len(resp.Hits.Hits) < qq.Limit {
+			break
+		}
+	}
+
+	return nil, nil
+}
+
+// scroll runs paged search with scroll for clusters without point in time
+func (c *elasticlient) scroll(ctx context.Context, s *transport.Session, index string, q *query.Query, page func(resp *response) error) error {
+	qq := *q
+	if qq.Limit > maxRecordsPerRequest {
+		qq.Limit = maxRecordsPerRequest
+	}
+
+	body, err := ComposeRequest(&qq, nil)
+	if err != nil {
+		return fmt.Errorf("failed to compose request: %w", err)
+	}
+
+	resp := &response{}
+	if err := doJSON(ctx, s, "POST", fmt.Sprintf("/%s/_search?scroll=%s", index, keepAlive), body, resp); err != nil {
+		return err
+	}
+
+	// scroll is cleared even when the query is interrupted
+	scrollID := resp.ScrollID
+	defer func() {
+		if scrollID != "" {
+			clearScroll(context.Background(), s, scrollID)
+		}
+	}()
+
+	total := q.Limit
+	for len(resp.Hits.Hits) > 0 {
+		// scroll pages have the same size, so the last one is cut to the limit
+		if len(resp.Hits.Hits) > total {
+			resp.Hits.Hits = resp.Hits.Hits[:total]
+		}
+
+		total -= len(resp.Hits.Hits)
+		if err := page(resp); err != nil {
+			return err
+		}
 
-		if len(resp.Hits.Hits) < q.Limit {
+		if total <= 0 || scrollID == "" {
 			break
 		}
+
+		body, err := json.Marshal(map[string]string{"scroll": keepAlive, "scroll_id": scrollID})
+		if err != nil {
+			return fmt.Errorf("failed to marshal scroll request: %w", err)
+		}
+
+		resp = &response{}
+		if err := doJSON(ctx, s, "POST", "/_search/scroll", body, resp); err != nil {
+			return err
+		}
+
+		if resp.ScrollID != "" {
+			scrollID = resp.ScrollID
+		}
 	}
 
-	return io.MultiReader(readers...), nil
+	return nil
+}
+
+func (c *elasticlient) Get(ctx context.Context, e *config.Env, id string, q *query.Query, o query.Options) (io.Reader, error) {
+	index := q.Index
+	if index == "" {
+		index = e.Index
+	}
+
+	output, err := c.config.GetOutput(e, q.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output: %w", err)
+	}
+
+	if o.FromStdin {
+		return applyOutputFromReader(os.Stdin, output)
+	}
+
+	if index == "" {
+		return nil, fmt.Errorf("neither index was specified, nor default index for env was found")
+	}
+
+	fq := q
+	if !o.Raw && !o.AsCurl {
+		fq = filterSource(q, output)
+	}
+
+	// _doc API works only for a concrete index (or an alias pointing to a single one),
+	// patterns and lists of indices have to be searched with ids query
+	method := "GET"
+	path := fmt.Sprintf("/%s/_doc/%s%s", index, url.PathEscape(id), docSourceParams(fq))
+	body := []byte(nil)
+	if strings.ContainsAny(index, "*,") {
+		method = "POST"
+		path = fmt.Sprintf("/%s/_search", index)
+		body, err = composeIDsRequest(fq, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose request: %w", err)
+		}
+	}
+
+	s := newSession(ctx, e)
+	req, err := newRequest(ctx, s, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.AsCurl {
+		return asCurl(req, body), nil
+	}
+
+	res, err := s.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+
+	// raw body is read by the caller
+	if o.Raw && res.StatusCode == 200 {
+		return res.Body, nil
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound && method == "GET" {
+		return nil, fmt.Errorf("document with id='%s' not found in index='%s'", id, index)
+	}
+
+	if res.StatusCode != 200 {
+		errBody, _ := ioutil.ReadAll(res.Body)
+		return nil, &statusError{Code: res.StatusCode, Body: string(errBody)}
+	}
+
+	if o.Recursive != nil {
+		output.Decode = config.FromStringList(*o.Recursive)
+	}
+
+	var resp *response
+	if method == "GET" {
+		resp, err = parseDocResponse(res.Body)
+	} else {
+		resp, err = parseResponse(res.Body)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Hits.Hits) == 0 {
+		return nil, fmt.Errorf("document with id='%s' not found in index='%s'", id, index)
+	}
+
+	return applyOutput(resp, output)
 }
 
 func NewClient(cfg *config.Config) client.Client {
@@ -161,6 +500,115 @@ func parseResponse(r io.Reader) (*response, error) {
 	return &resp, nil
 }
 
+func parseDocResponse(r io.Reader) (*response, error) {
+	j, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read all: %w", err)
+	}
+
+	doc := hit{}
+
+	err = json.Unmarshal(j, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	resp := response{}
+	if doc.Found {
+		resp.Hits.Hits = []hit{doc}
+	}
+
+	return &resp, nil
+}
+
+// newRequest creates request to the session endpoint with authorization headers of the env
+func newRequest(ctx context.Context, s *transport.Session, method, path string, body []byte) (*http.Request, error) {
+	e := s.Env()
+	ep := s.Endpoint() + path
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	// elastiq only reads data, so every request is safe to retry
+	req, err := http.NewRequestWithContext(transport.Idempotent(ctx), method, ep, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+
+	req.Header.Add("content-type", "application/json")
+	if e.Authorization != nil {
+		for k, v := range e.Authorization.Header {
+			value, err := v.GetValue()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get value of header='%s': %w", k, err)
+			}
+
+			req.Header.Add(k, value)
+		}
+	}
+
+	return req, nil
+}
+
+// statusError is returned for responses with unexpected http code
+type statusError struct {
+	Code int
+	Body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("got unexpected http code=%d, body='%s'", e.Code, e.Body)
+}
+
+// doJSON sends a request and decodes JSON response into v
+func doJSON(ctx context.Context, s *transport.Session, method, path string, body []byte, v interface{}) error {
+	req, err := newRequest(ctx, s, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	j, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read all: %w", err)
+	}
+
+	if res.StatusCode != 200 {
+		return &statusError{Code: res.StatusCode, Body: string(j)}
+	}
+
+	if err := json.Unmarshal(j, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+func asCurl(req *http.Request, body []byte) io.Reader {
+	str := "curl"
+	if req.Method != "GET" && req.Method != "POST" {
+		str += fmt.Sprintf(" -X %s", req.Method)
+	}
+
+	if body != nil {
+		str += fmt.Sprintf(" -d '%s'", string(body))
+	}
+
+	for k, v := range req.Header {
+		str += fmt.Sprintf(" -H '%s: %s'", k, v[0])
+	}
+	str += fmt.Sprintf(" '%s'\n", req.URL.String())
+
+	return strings.NewReader(str)
+}
+
 func applyOutputFromReader(r io.Reader, o *config.Output) (io.Reader, error) {
 	resp, err := parseResponse(r)
 	if err != nil {
@@ -172,17 +620,9 @@ func applyOutputFromReader(r io.Reader, o *config.Output) (io.Reader, error) {
 
 func applyOutput(resp *response, o *config.Output) (io.Reader, error) {
 	records := make([]map[string]interface{}, 0, len(resp.Hits.Hits))
-	for _, v := range resp.Hits.Hits {
-		r := make(map[string]interface{}, len(v.Source))
-		for k, v := range v.Source {
-			r[k] = v.Unwrap()
-		}
-		records = append(records, output.ApplyOutputFilters(r, o))
-	}
-
-	if o.Format == "json" {
-		return output.JSONOutput(records)
+	for i := range resp.Hits.Hits {
+		records = append(records, output.ApplyOutputFilters(unwrapSource(&resp.Hits.Hits[i]), o))
 	}
 
-	return nil, fmt.Errorf("format='%s' is not implemented", o.Format)
+	return output.FormatOutput(records, o)
 }
@@ -0,0 +1,130 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocs returns n documents with ascending timestamps
+func fakeDocs(n int) []fakeDoc {
+	docs := make([]fakeDoc, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, fakeDoc{
+			ID:     fmt.Sprintf("doc-%d", i),
+			Source: map[string]interface{}{"@timestamp": float64(1626256800000 + i), "message": fmt.Sprintf("message %d", i)},
+		})
+	}
+
+	return docs
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		name       string
+		docs       int
+		limit      int
+		pitStatus  int
+		noShardDoc bool
+		searches   int
+	}{
+		{name: "single request", docs: 50, limit: 20, searches: 1},
+		{name: "point in time", docs: 25000, limit: 22000, searches: 3},
+		{name: "point in time exhausted", docs: 12000, limit: 30000, searches: 2},
+		{name: "point in time without _shard_doc", docs: 25000, limit: 22000, noShardDoc: true, searches: 3},
+		{name: "scroll without point in time API", docs: 25000, limit: 22000, pitStatus: http.StatusNotFound, searches: 1},
+		{name: "scroll without open_point_in_time privilege", docs: 12000, limit: 30000, pitStatus: http.StatusForbidden, searches: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, srv := newFakeCluster(t, fakeDocs(tt.docs))
+			defer srv.Close()
+			fc.pitStatus = tt.pitStatus
+			fc.noShardDoc = tt.noShardDoc
+
+			cfg, e := fakeEnv(t, srv)
+			c := NewClient(cfg).(*elasticlient)
+
+			seen := map[string]bool{}
+			count := 0
+			_, err := c.pages(context.Background(), e, &query.Query{Limit: tt.limit}, query.Options{}, func(resp *response) error {
+				for _, h := range resp.Hits.Hits {
+					require.False(t, seen[h.ID], "record='%s' is repeated", h.ID)
+					seen[h.ID] = true
+				}
+
+				count += len(resp.Hits.Hits)
+				return nil
+			})
+			require.NoError(t, err)
+
+			expected := tt.limit
+			if tt.docs < expected {
+				expected = tt.docs
+			}
+			require.Equal(t, expected, count)
+			require.Len(t, fc.searches(), tt.searches)
+
+			// only the first search is rejected, the next ones keep _id tiebreaker
+			if tt.noShardDoc {
+				require.Equal(t, 1, fc.rejected)
+				for _, s := range fc.searches() {
+					sorts := s["sort"].([]interface{})
+					require.Contains(t, sorts[len(sorts)-1], "_id")
+				}
+			}
+
+			// point in time and scroll are released
+			require.Equal(t, fc.pits, fc.closed)
+			require.Equal(t, len(fc.scrolls), fc.cleared)
+		})
+	}
+}
+
+func TestPagesSearchAfter(t *testing.T) {
+	fc, srv := newFakeCluster(t, fakeDocs(15000))
+	defer srv.Close()
+
+	cfg, e := fakeEnv(t, srv)
+	c := NewClient(cfg).(*elasticlient)
+
+	_, err := c.pages(context.Background(), e, &query.Query{Limit: 15000}, query.Options{}, func(resp *response) error { return nil })
+	require.NoError(t, err)
+
+	searches := fc.searches()
+	require.Len(t, searches, 2)
+	require.Nil(t, searches[0]["search_after"])
+
+	// pages are descending by timestamp, the next one starts after all sort values of the last record including the tiebreaker
+	require.Equal(t, []interface{}{float64(1626256805000), float64(5000)}, searches[1]["search_after"])
+	require.Equal(t, map[string]interface{}{"id": "pit-1", "keep_alive": keepAlive}, searches[1]["pit"])
+}
+
+func TestPagesInterrupted(t *testing.T) {
+	fc, srv := newFakeCluster(t, fakeDocs(25000))
+	defer srv.Close()
+
+	cfg, e := fakeEnv(t, srv)
+	c := NewClient(cfg).(*elasticlient)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupted := errors.New("interrupted")
+	_, err := c.pages(ctx, e, &query.Query{Limit: 25000}, query.Options{}, func(resp *response) error {
+		cancel()
+		return interrupted
+	})
+	require.True(t, errors.Is(err, interrupted))
+
+	// point in time is closed although the context is cancelled
+	require.Equal(t, 1, fc.pits)
+	require.Equal(t, 1, fc.closed)
+}
@@ -0,0 +1,367 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"elastiq/config"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDoc is a document of fake cluster
+type fakeDoc struct {
+	ID     string
+	Source map[string]interface{}
+}
+
+// fakeCluster emulates search API of elasticsearch (sort, search_after, point in time, scroll and slices)
+// over a list of documents, so paging can be tested without a cluster
+type fakeCluster struct {
+	t    *testing.T
+	docs []fakeDoc
+
+	// pitStatus is returned by point in time API instead of opening it
+	pitStatus int
+	// types are mapping types of fields returned by field capabilities API
+	types map[string]string
+	// noShardDoc rejects searches sorted by _shard_doc as clusters before 7.12 do
+	noShardDoc bool
+
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	pits     int
+	closed   int
+	scrolls  map[string][]fakeHit
+	size     int
+	cleared  int
+	rejected int
+}
+
+type fakeHit struct {
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+	Sort   []interface{}          `json:"sort"`
+}
+
+func newFakeCluster(t *testing.T, docs []fakeDoc) (*fakeCluster, *httptest.Server) {
+	fc := &fakeCluster{t: t, docs: docs, scrolls: map[string][]fakeHit{}, types: map[string]string{"@timestamp": "date"}}
+	return fc, httptest.NewServer(fc)
+}
+
+// fakeEnv returns config with env dev pointing to the server along with extra settings
+func fakeEnv(t *testing.T, srv *httptest.Server, extra ...string) (*config.Config, *config.Env) {
+	cfg := config.Config{}
+	_, err := toml.Decode(fmt.Sprintf(`
+[env.dev]
+endpoints = ["%s"]
+index     = "logs"
+`, srv.URL)+strings.Join(extra, "\n"), &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	e, err := cfg.GetEnv("dev")
+	require.NoError(t, err)
+
+	return &cfg, e
+}
+
+// searches returns bodies of search requests
+func (fc *fakeCluster) searches() []map[string]interface{} {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return append([]map[string]interface{}{}, fc.requests...)
+}
+
+func (fc *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	data, _ := ioutil.ReadAll(r.Body)
+	body := map[string]interface{}{}
+	if len(data) > 0 {
+		require.NoError(fc.t, json.Unmarshal(data, &body))
+	}
+
+	reply := func(v interface{}) {
+		require.NoError(fc.t, json.NewEncoder(w).Encode(v))
+	}
+
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/_pit"):
+		if fc.pitStatus != 0 {
+			w.WriteHeader(fc.pitStatus)
+			return
+		}
+
+		fc.pits++
+		reply(map[string]string{"id": fmt.Sprintf("pit-%d", fc.pits)})
+
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/_field_caps"):
+		field := r.URL.Query().Get("fields")
+		fc.requests = append(fc.requests, map[string]interface{}{"field_caps": field})
+		reply(map[string]interface{}{"fields": map[string]interface{}{field: map[string]interface{}{fc.types[field]: map[string]bool{"searchable": true}}}})
+
+	case r.Method == "DELETE" && r.URL.Path == "/_pit":
+		fc.closed++
+		reply(map[string]bool{"succeeded": true})
+
+	case r.Method == "DELETE" && r.URL.Path == "/_search/scroll":
+		fc.cleared++
+		reply(map[string]bool{"succeeded": true})
+
+	case r.Method == "POST" && r.URL.Path == "/_search/scroll":
+		id, _ := body["scroll_id"].(string)
+		reply(fc.nextScroll(id, 0))
+
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/_search") && fc.noShardDoc && strings.Contains(string(data), "_shard_doc"):
+		fc.rejected++
+		w.WriteHeader(http.StatusBadRequest)
+		reply(map[string]interface{}{"error": map[string]string{"reason": "No mapping found for [_shard_doc] in order to sort on"}})
+
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/_search"):
+		fc.requests = append(fc.requests, body)
+		hits := fc.search(body)
+
+		if r.URL.Query().Get("scroll") != "" {
+			id := fmt.Sprintf("scroll-%d", len(fc.scrolls)+1)
+			fc.scrolls[id] = hits
+			reply(fc.nextScroll(id, int(body["size"].(float64))))
+			return
+		}
+
+		total := len(hits)
+		size := int(body["size"].(float64))
+		if len(hits) > size {
+			hits = hits[:size]
+		}
+
+		resp := map[string]interface{}{"hits": map[string]interface{}{"hits": hits, "total": map[string]int{"value": total}}}
+		if pit, ok := body["pit"].(map[string]interface{}); ok {
+			resp["pit_id"] = pit["id"]
+		}
+		reply(resp)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// nextScroll returns the next page of scroll, the first call sets page size
+func (fc *fakeCluster) nextScroll(id string, size int) map[string]interface{} {
+	hits := fc.scrolls[id]
+	if size == 0 {
+		size = fc.size
+	}
+	fc.size = size
+
+	page := hits
+	if len(page) > size {
+		page = page[:size]
+	}
+	fc.scrolls[id] = hits[len(page):]
+
+	return map[string]interface{}{
+		"_scroll_id": id,
+		"hits":       map[string]interface{}{"hits": page, "total": len(hits)},
+	}
+}
+
+// search returns sorted hits matching range filters and slice of request after its search_after
+func (fc *fakeCluster) search(body map[string]interface{}) []fakeHit {
+	sorts, _ := body["sort"].([]interface{})
+	after, _ := body["search_after"].([]interface{})
+
+	hits := []fakeHit{}
+	for i, d := range fc.docs {
+		if !fc.matches(d, i, body) {
+			continue
+		}
+
+		h := fakeHit{ID: d.ID, Source: d.Source}
+		for _, s := range sorts {
+			for key, v := range s.(map[string]interface{}) {
+				h.Sort = append(h.Sort, sortValue(d, i, key, v.(map[string]interface{})))
+			}
+		}
+		hits = append(hits, h)
+	}
+
+	less := func(a, b []interface{}) bool {
+		for k, s := range sorts {
+			for _, v := range s.(map[string]interface{}) {
+				c := compareSortValues(a[k], b[k])
+				if c == 0 {
+					continue
+				}
+
+				if v.(map[string]interface{})["order"] == "desc" {
+					return c > 0
+				}
+
+				return c < 0
+			}
+		}
+
+		return false
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return less(hits[i].Sort, hits[j].Sort) })
+
+	if after != nil {
+		for len(hits) > 0 && !less(after, hits[0].Sort) {
+			hits = hits[1:]
+		}
+	}
+
+	return hits
+}
+
+// matches checks range, term, exists and ids filters and slice of the request
+func (fc *fakeCluster) matches(d fakeDoc, pos int, body map[string]interface{}) bool {
+	if slice, ok := body["slice"].(map[string]interface{}); ok {
+		key := pos
+		if field, ok := slice["field"].(string); ok {
+			// documents without the field are in no slice
+			v, ok := d.Source[field].(float64)
+			if !ok {
+				return false
+			}
+			key = int(v)
+		}
+
+		if key%int(slice["max"].(float64)) != int(slice["id"].(float64)) {
+			return false
+		}
+	}
+
+	q, _ := body["query"].(map[string]interface{})
+	if ids, ok := q["ids"].(map[string]interface{}); ok {
+		for _, id := range ids["values"].([]interface{}) {
+			if id == d.ID {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	b, _ := q["bool"].(map[string]interface{})
+	mustnots, _ := b["must_not"].([]interface{})
+	for _, f := range mustnots {
+		if exists, ok := f.(map[string]interface{})["exists"].(map[string]interface{}); ok {
+			if _, ok := d.Source[exists["field"].(string)]; ok {
+				return false
+			}
+		}
+	}
+
+	filters, _ := b["filter"].([]interface{})
+	for _, f := range filters {
+		if exists, ok := f.(map[string]interface{})["exists"].(map[string]interface{}); ok {
+			if _, ok := d.Source[exists["field"].(string)]; !ok {
+				return false
+			}
+		}
+
+		if term, ok := f.(map[string]interface{})["term"].(map[string]interface{}); ok {
+			for field, cond := range term {
+				v := d.Source[field]
+				if field == "_id" {
+					v = d.ID
+				}
+
+				if fmt.Sprint(v) != fmt.Sprint(cond.(map[string]interface{})["value"]) {
+					return false
+				}
+			}
+		}
+
+		rng, ok := f.(map[string]interface{})["range"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for field, cond := range rng {
+			v, ok := d.Source[field].(float64)
+			if !ok {
+				return false
+			}
+
+			for op, bound := range cond.(map[string]interface{}) {
+				if op == "format" {
+					continue
+				}
+
+				var limit float64
+				fmt.Sscan(fmt.Sprint(bound), &limit)
+				if op == "gte" && v < limit || op == "gt" && v <= limit || op == "lte" && v > limit || op == "lt" && v >= limit {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// sortValue returns sort value of document as elasticsearch does,
+// missing values are sorted last by default with the greatest (or the least for descending order) long,
+// which is kept int64 to be encoded exactly
+func sortValue(d fakeDoc, pos int, key string, order map[string]interface{}) interface{} {
+	switch key {
+	case "_shard_doc":
+		return float64(pos)
+	case "_id":
+		return d.ID
+	}
+
+	if v, ok := d.Source[key]; ok {
+		return v
+	}
+
+	last := order["missing"] != "_first"
+	if (order["order"] == "desc") == last {
+		return int64(math.MinInt64)
+	}
+
+	return int64(math.MaxInt64)
+}
+
+func compareSortValues(a, b interface{}) int {
+	af, aok := sortNumber(a)
+	bf, bok := sortNumber(b)
+	if aok && bok {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+
+		return 0
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func sortNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
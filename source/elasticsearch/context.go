@@ -0,0 +1,245 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"elastiq/config"
+	"elastiq/output"
+	"elastiq/query"
+	"elastiq/transport"
+)
+
+const anchorField = "_anchor"
+
+func (c *elasticlient) Context(ctx context.Context, e *config.Env, a *query.Anchor, q *query.Query, o query.Options) (io.Reader, error) {
+	index := q.Index
+	if index == "" {
+		index = e.Index
+	}
+
+	out, err := c.config.GetOutput(e, q.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output: %w", err)
+	}
+
+	if o.FromStdin {
+		return applyOutputFromReader(os.Stdin, out)
+	}
+
+	if index == "" {
+		return nil, fmt.Errorf("neither index was specified, nor default index for env was found")
+	}
+
+	if o.Recursive != nil {
+		out.Decode = config.FromStringList(*o.Recursive)
+	}
+
+	// records around anchor are ordered by a single key, the tiebreaker is added to it
+	if len(q.Order) > 1 {
+		return nil, fmt.Errorf("context is ordered by a single key, got %d keys in order", len(q.Order))
+	}
+
+	by := "@timestamp"
+	if len(q.Order) > 0 {
+		by = q.Order[0].By
+	}
+
+	s := newSession(ctx, e)
+	var anchor *hit
+	var before, after *response
+
+	switch {
+	case a.ID != "":
+		anchor, before, after, err = c.aroundRecord(ctx, s, index, by, a, q.Filters, out)
+	case a.Time != nil:
+		if len(a.Same) > 0 {
+			return nil, fmt.Errorf("same-as fields require anchor document id, use filters with time anchor")
+		}
+
+		before, after, err = c.aroundTime(ctx, s, index, by, a, q.Filters, out)
+	default:
+		return nil, fmt.Errorf("either anchor id or anchor time has to be specified")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]map[string]interface{}, 0, len(before.Hits.Hits)+len(after.Hits.Hits)+1)
+	for i := len(before.Hits.Hits) - 1; i >= 0; i-- {
+		records = append(records, output.ApplyOutputFilters(unwrapSource(&before.Hits.Hits[i]), out))
+	}
+
+	if anchor != nil {
+		r := output.ApplyOutputFilters(unwrapSource(anchor), out)
+		r[anchorField] = true
+		records = append(records, r)
+	}
+
+	for i := range after.Hits.Hits {
+		records = append(records, output.ApplyOutputFilters(unwrapSource(&after.Hits.Hits[i]), out))
+	}
+
+	return output.FormatOutput(records, out)
+}
+
+// aroundRecord finds anchor record by id along with records before and after it,
+// records are searched in point in time with its tiebreaker (or with _id tiebreaker for clusters without it),
+// so records having the same sort value as the anchor are neither lost nor repeated
+func (c *elasticlient) aroundRecord(
+	ctx context.Context, s *transport.Session, index, by string, a *query.Anchor, filters []*query.Filter, out *config.Output,
+) (*hit, *response, *response, error) {
+	pit, err := openPIT(ctx, s, index, keepAlive)
+	if err != nil && !errors.Is(err, errPITUnsupported) {
+		return nil, nil, nil, err
+	}
+
+	if pit != nil {
+		// point in time is closed even when the command is interrupted
+		defer closePIT(context.Background(), s, pit)
+	}
+
+	orders := func(ascending bool) []*query.Order {
+		orders := []*query.Order{{By: by, Ascending: ascending}}
+		if pit == nil {
+			orders = append(orders, &query.Order{By: "_id", Ascending: ascending})
+		}
+
+		return orders
+	}
+
+	// fields anchor is matched by have to be fetched regardless of output
+	aq := filterSource(&query.Query{
+		Filters: []*query.Filter{{Key: "_id", Operation: query.TEQ, Value: []string{a.ID}}},
+		Order:   orders(false),
+		Limit:   1,
+	}, out, a.Same...)
+
+	resp, err := c.search(ctx, s, index, pit, aq, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to find anchor record: %w", err)
+	}
+
+	if len(resp.Hits.Hits) == 0 {
+		return nil, nil, nil, fmt.Errorf("document with id='%s' not found in index='%s'", a.ID, index)
+	}
+
+	anchor := &resp.Hits.Hits[0]
+
+	source := unwrapSource(anchor)
+	filters = append([]*query.Filter{}, filters...)
+	for _, key := range a.Same {
+		v, ok := output.Lookup(source, key)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("anchor record has no field='%s'", key)
+		}
+
+		filters = append(filters, &query.Filter{Key: key, Operation: query.EQ, Value: []string{fmt.Sprint(v)}})
+	}
+
+	before, err := c.search(ctx, s, index, pit, filterSource(&query.Query{Filters: filters, Order: orders(false), Limit: a.Size}, out), anchor.Sort)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to query records before anchor: %w", err)
+	}
+
+	after, err := c.search(ctx, s, index, pit, filterSource(&query.Query{Filters: filters, Order: orders(true), Limit: a.Size}, out), anchor.Sort)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to query records after anchor: %w", err)
+	}
+
+	return anchor, before, after, nil
+}
+
+// aroundTime finds records before the anchor time and records at or after it,
+// time is compared by range filters with explicit format, so it works for date and date_nanos fields of any mapping format
+func (c *elasticlient) aroundTime(
+	ctx context.Context, s *transport.Session, index, by string, a *query.Anchor, filters []*query.Filter, out *config.Output,
+) (*response, *response, error) {
+	types, err := fieldTypes(ctx, s, index, by)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, t := range types {
+		if t != "date" && t != "date_nanos" {
+			return nil, nil, fmt.Errorf("anchor time requires order field='%s' of date type, got type='%s'", by, t)
+		}
+	}
+
+	at := anchorTime(*a.Time)
+	beforeFilters := append(append([]*query.Filter{}, filters...), &query.Filter{Key: by, Operation: query.LT, Value: []string{at}, Format: "epoch_millis"})
+	afterFilters := append(append([]*query.Filter{}, filters...), &query.Filter{Key: by, Operation: query.GTE, Value: []string{at}, Format: "epoch_millis"})
+
+	before, err := c.search(ctx, s, index, nil, filterSource(&query.Query{Filters: beforeFilters, Order: []*query.Order{{By: by}}, Limit: a.Size}, out), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query records before anchor: %w", err)
+	}
+
+	after, err := c.search(ctx, s, index, nil, filterSource(&query.Query{Filters: afterFilters, Order: []*query.Order{{By: by, Ascending: true}}, Limit: a.Size}, out), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query records after anchor: %w", err)
+	}
+
+	return before, after, nil
+}
+
+// anchorTime formats t as epoch_millis, fraction of millisecond is kept for date_nanos fields
+func anchorTime(t time.Time) string {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if ns := t.UnixNano() % int64(time.Millisecond); ns != 0 {
+		return fmt.Sprintf("%d.%06d", ms, ns)
+	}
+
+	return strconv.FormatInt(ms, 10)
+}
+
+// search runs a single search request (in point in time unless it is nil) and returns parsed response
+func (c *elasticlient) search(ctx context.Context, s *transport.Session, index string, pit *PIT, q *query.Query, sf query.StartFrom) (*response, error) {
+	if pit != nil {
+		return searchPIT(ctx, s, pit, func() ([]byte, error) {
+			return ComposePITRequest(q, sf, pit)
+		})
+	}
+
+	body, err := ComposeRequest(q, sf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose request: %w", err)
+	}
+
+	resp := response{}
+	if err := doJSON(ctx, s, "POST", fmt.Sprintf("/%s/_search", index), body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// unwrapSource returns _source of hit along with requested fields,
+// which are put by their names unless _source already has them
+func unwrapSource(h *hit) map[string]interface{} {
+	r := make(map[string]interface{}, len(h.Source)+len(h.Fields))
+	for k, v := range h.Source {
+		r[k] = v.Unwrap()
+	}
+
+	for k, v := range h.Fields {
+		if _, ok := output.Lookup(r, k); ok {
+			continue
+		}
+
+		// fields are always returned as arrays
+		vv := v.Unwrap()
+		if a, ok := vv.([]interface{}); ok && len(a) == 1 {
+			vv = a[0]
+		}
+		r[k] = vv
+	}
+
+	return r
+}
@@ -0,0 +1,139 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+// contextDocs have the same timestamp in the middle
+func contextDocs() []fakeDoc {
+	docs := []fakeDoc{}
+	for i, ts := range []float64{1000, 2000, 2000, 2000, 3000} {
+		docs = append(docs, fakeDoc{
+			ID:     string(rune('a' + i)),
+			Source: map[string]interface{}{"@timestamp": ts, "pod": "pod-1", "id": string(rune('a' + i))},
+		})
+	}
+
+	return docs
+}
+
+func TestContextAroundRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		pitStatus  int
+		noShardDoc bool
+		tiebreak   string
+	}{
+		{name: "point in time", tiebreak: "_shard_doc"},
+		{name: "point in time without _shard_doc", noShardDoc: true, tiebreak: "_id"},
+		{name: "without point in time", pitStatus: http.StatusNotFound, tiebreak: "_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, srv := newFakeCluster(t, contextDocs())
+			defer srv.Close()
+			fc.pitStatus = tt.pitStatus
+			fc.noShardDoc = tt.noShardDoc
+
+			cfg, e := fakeEnv(t, srv)
+			c := NewClient(cfg).(*elasticlient)
+
+			r, err := c.Context(context.Background(), e, &query.Anchor{ID: "c", Same: []string{"pod"}, Size: 10}, &query.Query{}, query.Options{})
+			require.NoError(t, err)
+
+			records := decodeRecords(t, r)
+
+			// records with the same timestamp as the anchor are on both sides of it
+			require.Equal(t, []interface{}{"a", "b", "c", "d", "e"}, field(records, "id"))
+			require.Equal(t, []interface{}{nil, nil, true, nil, nil}, field(records, anchorField))
+
+			searches := fc.searches()
+			require.Len(t, searches, 3)
+			for _, s := range searches {
+				sorts := s["sort"].([]interface{})
+				require.Len(t, sorts, 2)
+				require.Contains(t, sorts[1], tt.tiebreak)
+			}
+
+			require.Equal(t, fc.pits, fc.closed)
+		})
+	}
+}
+
+func TestContextAroundTime(t *testing.T) {
+	fc, srv := newFakeCluster(t, contextDocs())
+	defer srv.Close()
+
+	cfg, e := fakeEnv(t, srv)
+	c := NewClient(cfg).(*elasticlient)
+
+	at := time.Unix(2, 0)
+	r, err := c.Context(context.Background(), e, &query.Anchor{Time: &at, Size: 10}, &query.Query{}, query.Options{})
+	require.NoError(t, err)
+
+	// records at the anchor time go after it
+	records := decodeRecords(t, r)
+	require.Equal(t, []interface{}{"a", "b", "c", "d", "e"}, field(records, "id"))
+	require.Equal(t, []interface{}{nil, nil, nil, nil, nil}, field(records, anchorField))
+
+	searches := fc.searches()
+	require.Len(t, searches, 3)
+	require.Equal(t, map[string]interface{}{"field_caps": "@timestamp"}, searches[0])
+	require.Equal(t, []interface{}{map[string]interface{}{
+		"range": map[string]interface{}{"@timestamp": map[string]interface{}{"lt": "2000", "format": "epoch_millis"}},
+	}}, searches[1]["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"])
+
+	fc.types["@timestamp"] = "keyword"
+	_, err = c.Context(context.Background(), e, &query.Anchor{Time: &at, Size: 10}, &query.Query{}, query.Options{})
+	require.EqualError(t, err, "anchor time requires order field='@timestamp' of date type, got type='keyword'")
+}
+
+func TestContextMultiKeyOrder(t *testing.T) {
+	_, srv := newFakeCluster(t, contextDocs())
+	defer srv.Close()
+
+	cfg, e := fakeEnv(t, srv)
+	c := NewClient(cfg).(*elasticlient)
+
+	orders, err := query.GetOrders("pod/asc,@timestamp/desc")
+	require.NoError(t, err)
+
+	_, err = c.Context(context.Background(), e, &query.Anchor{ID: "c", Size: 10}, &query.Query{Order: orders}, query.Options{})
+	require.EqualError(t, err, "context is ordered by a single key, got 2 keys in order")
+}
+
+func TestAnchorTime(t *testing.T) {
+	require.Equal(t, "1626256800123", anchorTime(time.Unix(1626256800, 123000000)))
+	require.Equal(t, "1626256800123.000456", anchorTime(time.Unix(1626256800, 123000456)))
+}
+
+func decodeRecords(t *testing.T, r io.Reader) []map[string]interface{} {
+	records := []map[string]interface{}{}
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		rec := map[string]interface{}{}
+		require.NoError(t, dec.Decode(&rec))
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func field(records []map[string]interface{}, key string) []interface{} {
+	values := []interface{}{}
+	for _, r := range records {
+		values = append(values, r[key])
+	}
+
+	return values
+}
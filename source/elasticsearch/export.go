@@ -0,0 +1,367 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/output"
+	"elastiq/query"
+	"elastiq/transport"
+)
+
+// slice is an exported slice along with its state, which is updated by every exported page
+type slice struct {
+	id    int
+	max   int
+	state client.SliceState
+	// resumed is the value slice was resumed after, records with it have to be checked against ids exported before
+	resumed interface{}
+	seen    map[string]bool
+	// counted is set once total of the slice search is added to the state
+	counted bool
+	// missing is set for the slice exporting records without the timestamp after the others,
+	// requery is set when it starts doing so
+	missing bool
+	requery bool
+}
+
+// timestamp is the field slices are ordered by, its sort values are turned into range values to resume slices
+type timestamp struct {
+	field string
+	// format of range values, which is empty for fields other than dates, sort values of them are used as they are
+	format string
+	// nanos is set when sort values are nanoseconds of date_nanos
+	nanos bool
+	// numericType makes indices mapping the field as date sort it by nanoseconds too
+	numericType string
+}
+
+func exportTimestamp(ctx context.Context, s *transport.Session, index, field string) (*timestamp, error) {
+	types, err := fieldTypes(ctx, s, index, field)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &timestamp{field: field}
+	date := false
+	for _, t := range types {
+		switch t {
+		case "date":
+			date = true
+			ts.format = "epoch_millis"
+		case "date_nanos":
+			ts.nanos = true
+			ts.format = "epoch_millis"
+		}
+	}
+
+	if date && ts.nanos {
+		ts.numericType = "date_nanos"
+	}
+
+	return ts, nil
+}
+
+// after returns filter of records sorted at or after sort value v
+func (ts *timestamp) after(v interface{}) (*query.Filter, error) {
+	value := fmt.Sprint(v)
+	if ts.nanos {
+		ns, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sort value='%s': %w", value, err)
+		}
+
+		value = anchorTime(time.Unix(0, ns))
+	}
+
+	return &query.Filter{Key: ts.field, Operation: query.GTE, Value: []string{value}, Format: ts.format}, nil
+}
+
+// sameSortValue compares sort values by their json representation, so longs are compared without precision loss
+func sameSortValue(a, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func (c *elasticlient) Export(ctx context.Context, e *config.Env, q *query.Query, o query.Options, slices []*client.SliceState, batches chan<- *client.Batch) error {
+	index := q.Index
+	if index == "" {
+		index = e.Index
+	}
+
+	if index == "" {
+		return fmt.Errorf("neither index was specified, nor default index for env was found")
+	}
+
+	out, err := c.config.GetOutput(e, q.Output)
+	if err != nil {
+		return fmt.Errorf("failed to get output: %w", err)
+	}
+
+	if o.Recursive != nil {
+		out.Decode = config.FromStringList(*o.Recursive)
+	}
+
+	// slices are ordered by sort values, which do not need fields in _source
+	fq := filterSource(q, out)
+
+	s := newSession(ctx, e)
+
+	ts, err := exportTimestamp(ctx, s, index, e.GetRecordFields().Timestamp)
+	if err != nil {
+		return err
+	}
+
+	// records are distributed among slices by the timestamp in point in time,
+	// since default _shard_doc distribution changes between points in time and resumed slices would get other records
+	pit, err := openPIT(ctx, s, index, exportKeepAlive)
+	if err != nil && !errors.Is(err, errPITUnsupported) {
+		return err
+	}
+
+	if pit != nil {
+		// point in time is closed even when export is interrupted
+		defer closePIT(context.Background(), s, pit)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(slices))
+	for i, st := range slices {
+		if st.Done {
+			continue
+		}
+
+		sl := &slice{
+			id:      i,
+			max:     len(slices),
+			state:   *st,
+			resumed: st.After,
+			seen:    map[string]bool{},
+			// total of the search for records without the timestamp is added once it starts
+			counted: st.After != nil || st.Total != 0 && !st.Missing,
+			// slicing by the timestamp leaves records without it out of every slice of point in time,
+			// so the first slice exports them, scroll slices export their own ones
+			missing: pit == nil || i == 0,
+		}
+		for _, id := range st.IDs {
+			sl.seen[id] = true
+		}
+
+		wg.Add(1)
+		go func(sl *slice) {
+			defer wg.Done()
+
+			if pit != nil {
+				// every slice keeps its own point in time id, since it may change between pages
+				p := *pit
+				errs[sl.id] = c.exportPIT(ctx, s, &p, fq, sl, ts, out, batches)
+			} else {
+				errs[sl.id] = c.exportScroll(ctx, s, index, fq, sl, ts, out, batches)
+			}
+
+			if errs[sl.id] != nil {
+				cancel()
+			}
+		}(sl)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("failed to export slice=%d: %w", i, err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// sliceQuery returns query of slice ordered by timestamp, resumed slice starts from the timestamp of the last exported record,
+// records without the timestamp are searched on their own, since range of resumed slice does not match them
+func sliceQuery(q *query.Query, sl *slice, ts *timestamp) (*query.Query, error) {
+	qq := *q
+	qq.Order = []*query.Order{{By: ts.field, Ascending: true, NumericType: ts.numericType}}
+	qq.Filters = append([]*query.Filter{}, q.Filters...)
+
+	switch {
+	case sl.state.Missing:
+		qq.Filters = append(qq.Filters, &query.Filter{Key: ts.field, Operation: query.NEX})
+
+	case sl.resumed != nil:
+		f, err := ts.after(sl.resumed)
+		if err != nil {
+			return nil, err
+		}
+
+		qq.Filters = append(qq.Filters, f)
+
+	default:
+		qq.Filters = append(qq.Filters, &query.Filter{Key: ts.field, Operation: query.EX})
+	}
+
+	return &qq, nil
+}
+
+// emit sends batch of found records updating state of the slice,
+// records exported before resume are skipped
+func (sl *slice) emit(ctx context.Context, resp *response, size int, out *config.Output, batches chan<- *client.Batch) error {
+	if !sl.counted {
+		sl.state.Total += int64(resp.Hits.Total)
+		sl.counted = true
+	}
+
+	records := make([]map[string]interface{}, 0, len(resp.Hits.Hits))
+	for i := range resp.Hits.Hits {
+		h := &resp.Hits.Hits[i]
+
+		var value interface{}
+		if h.Sort != nil && len(*h.Sort) > 0 {
+			value = (*h.Sort)[0]
+		}
+
+		if sameSortValue(value, sl.resumed) && sl.seen[h.ID] {
+			continue
+		}
+
+		if !sameSortValue(value, sl.state.After) {
+			sl.state.After = value
+			sl.state.IDs = nil
+		}
+		sl.state.IDs = append(sl.state.IDs, h.ID)
+		sl.state.Exported++
+
+		records = append(records, output.ApplyOutputFilters(unwrapSource(h), out))
+	}
+
+	sl.state.Done = len(resp.Hits.Hits) < size
+
+	// records without the timestamp are exported from the start, since they were not searched yet
+	if sl.state.Done && sl.missing && !sl.state.Missing {
+		sl.state.Done = false
+		sl.state.Missing = true
+		sl.state.After = nil
+		sl.state.IDs = nil
+		sl.resumed = nil
+		sl.seen = map[string]bool{}
+		sl.counted = false
+		sl.requery = true
+	}
+
+	state := sl.state
+	state.IDs = append([]string(nil), sl.state.IDs...)
+
+	select {
+	case batches <- &client.Batch{Slice: sl.id, Records: records, State: state}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *elasticlient) exportPIT(ctx context.Context, s *transport.Session, pit *PIT, q *query.Query, sl *slice, ts *timestamp, out *config.Output, batches chan<- *client.Batch) error {
+	for !sl.state.Done {
+		sl.requery = false
+		qq, err := sliceQuery(q, sl, ts)
+		if err != nil {
+			return err
+		}
+
+		rs := &RawSlice{ID: sl.id, Max: sl.max, Field: ts.field}
+		if sl.state.Missing {
+			// records without the timestamp are in no slice, so they are searched in the whole point in time
+			rs = &RawSlice{ID: 0, Max: 1}
+		}
+
+		sf := query.StartFrom(nil)
+		for !sl.state.Done && !sl.requery {
+			resp, err := searchPIT(ctx, s, pit, func() ([]byte, error) {
+				return ComposeSliceRequest(qq, sf, pit, rs)
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(resp.Hits.Hits) > 0 {
+				sf = resp.Hits.Hits[len(resp.Hits.Hits)-1].Sort
+			}
+
+			if err := sl.emit(ctx, resp, qq.Limit, out, batches); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *elasticlient) exportScroll(ctx context.Context, s *transport.Session, index string, q *query.Query, sl *slice, ts *timestamp, out *config.Output, batches chan<- *client.Batch) error {
+	for {
+		sl.requery = false
+		if err := c.scrollSlice(ctx, s, index, q, sl, ts, out, batches); err != nil {
+			return err
+		}
+
+		if sl.state.Done || !sl.requery {
+			return nil
+		}
+	}
+}
+
+// scrollSlice exports slice until it is done or starts exporting records without the timestamp
+func (c *elasticlient) scrollSlice(ctx context.Context, s *transport.Session, index string, q *query.Query, sl *slice, ts *timestamp, out *config.Output, batches chan<- *client.Batch) error {
+	qq, err := sliceQuery(q, sl, ts)
+	if err != nil {
+		return err
+	}
+
+	// scroll slices distribute records by _id, so slices get the same records on resume
+	body, err := ComposeSliceRequest(qq, nil, nil, &RawSlice{ID: sl.id, Max: sl.max})
+	if err != nil {
+		return fmt.Errorf("failed to compose request: %w", err)
+	}
+
+	resp := &response{}
+	if err := doJSON(ctx, s, "POST", fmt.Sprintf("/%s/_search?scroll=%s", index, exportKeepAlive), body, resp); err != nil {
+		return err
+	}
+
+	scrollID := resp.ScrollID
+	defer func() {
+		if scrollID != "" {
+			clearScroll(context.Background(), s, scrollID)
+		}
+	}()
+
+	for {
+		if err := sl.emit(ctx, resp, qq.Limit, out, batches); err != nil {
+			return err
+		}
+
+		if sl.state.Done || sl.requery || scrollID == "" {
+			return nil
+		}
+
+		body, err := json.Marshal(map[string]string{"scroll": exportKeepAlive, "scroll_id": scrollID})
+		if err != nil {
+			return fmt.Errorf("failed to marshal scroll request: %w", err)
+		}
+
+		resp = &response{}
+		if err := doJSON(ctx, s, "POST", "/_search/scroll", body, resp); err != nil {
+			return err
+		}
+
+		if resp.ScrollID != "" {
+			scrollID = resp.ScrollID
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exportDocs have records with the same timestamp and records without it
+func exportDocs() []fakeDoc {
+	docs := []fakeDoc{}
+	for i := 0; i < 12; i++ {
+		source := map[string]interface{}{"id": fmt.Sprintf("doc-%02d", i)}
+		if i < 9 {
+			source["@timestamp"] = float64(1626256800000 + i/3)
+		}
+		docs = append(docs, fakeDoc{ID: fmt.Sprintf("doc-%02d", i), Source: source})
+	}
+
+	return docs
+}
+
+// page returns response of hits given by pairs of id and sort value
+func page(t *testing.T, pairs ...string) *response {
+	hits := []string{}
+	for i := 0; i < len(pairs); i += 2 {
+		hits = append(hits, fmt.Sprintf(`{"_id": "%s", "_source": {"id": "%s"}, "sort": [%s]}`, pairs[i], pairs[i], pairs[i+1]))
+	}
+
+	resp := &response{}
+	require.NoError(t, json.Unmarshal([]byte(`{"hits": {"total": 7, "hits": [`+strings.Join(hits, ",")+`]}}`), resp))
+	return resp
+}
+
+func Test_emit(t *testing.T) {
+	tests := []struct {
+		name    string
+		slice   slice
+		hits    []string
+		size    int
+		ids     []string
+		state   client.SliceState
+		requery bool
+	}{
+		{
+			name:  "fresh slice",
+			hits:  []string{"a", "1000", "b", "2000", "c", "2000"},
+			size:  3,
+			ids:   []string{"a", "b", "c"},
+			state: client.SliceState{After: json.Number("2000"), IDs: []string{"b", "c"}, Exported: 3, Total: 7},
+		},
+		{
+			name: "resumed slice skips exported records",
+			slice: slice{
+				state:   client.SliceState{After: json.Number("2000"), IDs: []string{"b"}, Exported: 2, Total: 7},
+				resumed: json.Number("2000"),
+				seen:    map[string]bool{"b": true},
+				counted: true,
+			},
+			hits:  []string{"b", "2000", "c", "2000", "d", "3000"},
+			size:  3,
+			ids:   []string{"c", "d"},
+			state: client.SliceState{After: json.Number("3000"), IDs: []string{"d"}, Exported: 4, Total: 7},
+		},
+		{
+			name:  "nanoseconds are kept",
+			hits:  []string{"a", "1626256800000000001"},
+			size:  3,
+			ids:   []string{"a"},
+			state: client.SliceState{After: json.Number("1626256800000000001"), IDs: []string{"a"}, Exported: 1, Total: 7, Done: true},
+		},
+		{
+			name:    "slice exporting records without the timestamp after its own",
+			slice:   slice{missing: true},
+			hits:    []string{"a", "1000"},
+			size:    2,
+			ids:     []string{"a"},
+			state:   client.SliceState{Missing: true, Exported: 1, Total: 7},
+			requery: true,
+		},
+		{
+			name:  "records without the timestamp are counted",
+			slice: slice{state: client.SliceState{Missing: true, Exported: 1, Total: 7}, missing: true},
+			hits:  []string{"b", "9223372036854775807"},
+			size:  2,
+			ids:   []string{"b"},
+			state: client.SliceState{After: json.Number("9223372036854775807"), IDs: []string{"b"}, Missing: true, Exported: 2, Total: 14, Done: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sl := tt.slice
+			if sl.seen == nil {
+				sl.seen = map[string]bool{}
+			}
+
+			batches := make(chan *client.Batch, 1)
+			require.NoError(t, sl.emit(context.Background(), page(t, tt.hits...), tt.size, &config.Output{}, batches))
+
+			b := <-batches
+			ids := []string{}
+			for _, r := range b.Records {
+				ids = append(ids, r["id"].(string))
+			}
+
+			require.Equal(t, tt.ids, ids)
+			require.Equal(t, tt.state, b.State)
+			require.Equal(t, tt.requery, sl.requery)
+		})
+	}
+}
+
+func Test_timestampAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		ts     timestamp
+		value  interface{}
+		filter query.Filter
+	}{
+		{
+			name:   "date",
+			ts:     timestamp{field: "@timestamp", format: "epoch_millis"},
+			value:  json.Number("1626256800000"),
+			filter: query.Filter{Key: "@timestamp", Operation: query.GTE, Value: []string{"1626256800000"}, Format: "epoch_millis"},
+		},
+		{
+			name:   "date_nanos",
+			ts:     timestamp{field: "@timestamp", format: "epoch_millis", nanos: true},
+			value:  json.Number("1626256800000000001"),
+			filter: query.Filter{Key: "@timestamp", Operation: query.GTE, Value: []string{"1626256800000.000001"}, Format: "epoch_millis"},
+		},
+		{
+			name:   "long",
+			ts:     timestamp{field: "seq"},
+			value:  json.Number("42"),
+			filter: query.Filter{Key: "seq", Operation: query.GTE, Value: []string{"42"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.ts.after(tt.value)
+			require.NoError(t, err)
+			require.Equal(t, tt.filter, *f)
+		})
+	}
+}
+
+func TestExportTimestamp(t *testing.T) {
+	fc, srv := newFakeCluster(t, nil)
+	defer srv.Close()
+
+	_, e := fakeEnv(t, srv)
+
+	fc.types = map[string]string{"@timestamp": "date_nanos"}
+	ts, err := exportTimestamp(context.Background(), newSession(context.Background(), e), "logs", "@timestamp")
+	require.NoError(t, err)
+	require.Equal(t, &timestamp{field: "@timestamp", format: "epoch_millis", nanos: true}, ts)
+
+	fc.types = map[string]string{"@timestamp": "keyword"}
+	ts, err = exportTimestamp(context.Background(), newSession(context.Background(), e), "logs", "@timestamp")
+	require.NoError(t, err)
+	require.Equal(t, &timestamp{field: "@timestamp"}, ts)
+}
+
+// export runs export until it is done or stop returns true for a batch,
+// it returns ids of exported records and updates states as checkpoint does
+func export(t *testing.T, c *elasticlient, e *config.Env, states []*client.SliceState, stop func(n int) bool) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan *client.Batch, len(states))
+	exportErr := make(chan error, 1)
+	go func() {
+		exportErr <- c.Export(ctx, e, &query.Query{Limit: 2}, query.Options{}, states, batches)
+		close(batches)
+	}()
+
+	ids := []string{}
+	n := 0
+	for b := range batches {
+		for _, r := range b.Records {
+			ids = append(ids, r["id"].(string))
+		}
+
+		state := b.State
+		states[b.Slice] = &state
+
+		n++
+		if stop(n) {
+			cancel()
+		}
+	}
+
+	if err := <-exportErr; err != nil {
+		require.True(t, errors.Is(err, context.Canceled), err)
+	}
+
+	return ids
+}
+
+func TestExportResume(t *testing.T) {
+	tests := []struct {
+		name       string
+		pitStatus  int
+		noShardDoc bool
+	}{
+		{name: "point in time"},
+		{name: "point in time without _shard_doc", noShardDoc: true},
+		{name: "scroll", pitStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := exportDocs()
+			expected := []string{}
+			for _, d := range docs {
+				expected = append(expected, d.ID)
+			}
+
+			for stopAt := 1; stopAt <= 8; stopAt++ {
+				t.Run(fmt.Sprintf("interrupted after %d batches", stopAt), func(t *testing.T) {
+					fc, srv := newFakeCluster(t, docs)
+					defer srv.Close()
+					fc.pitStatus = tt.pitStatus
+					fc.noShardDoc = tt.noShardDoc
+
+					cfg, e := fakeEnv(t, srv)
+					c := NewClient(cfg).(*elasticlient)
+
+					states := []*client.SliceState{{}, {}}
+					ids := export(t, c, e, states, func(n int) bool { return n == stopAt })
+
+					// states go through json as checkpoint does
+					data, err := json.Marshal(states)
+					require.NoError(t, err)
+					dec := json.NewDecoder(bytes.NewReader(data))
+					dec.UseNumber()
+					states = nil
+					require.NoError(t, dec.Decode(&states))
+
+					ids = append(ids, export(t, c, e, states, func(int) bool { return false })...)
+					require.True(t, states[0].Done && states[1].Done)
+
+					sort.Strings(ids)
+					require.Equal(t, expected, ids)
+
+					exported := states[0].Exported + states[1].Exported
+					total := states[0].Total + states[1].Total
+					require.Equal(t, int64(len(docs)), exported)
+					require.Equal(t, int64(len(docs)), total)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"elastiq/client"
+	"elastiq/config"
+	"elastiq/transport"
+)
+
+type fieldCapsResponse struct {
+	Fields map[string]map[string]struct {
+		Searchable   bool     `json:"searchable"`
+		Aggregatable bool     `json:"aggregatable"`
+		Indices      []string `json:"indices"`
+	} `json:"fields"`
+}
+
+func (c *elasticlient) Fields(ctx context.Context, e *config.Env, index string) ([]*client.FieldInfo, error) {
+	if index == "" {
+		index = e.Index
+	}
+
+	if index == "" {
+		return nil, fmt.Errorf("neither index was specified, nor default index for env was found")
+	}
+
+	resp := fieldCapsResponse{}
+	path := fmt.Sprintf("/%s/_field_caps?fields=*", index)
+	if err := doJSON(ctx, newSession(ctx, e), "GET", path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get field capabilities: %w", err)
+	}
+
+	return parseFieldCaps(&resp), nil
+}
+
+// fieldTypes returns types field is mapped to in index
+func fieldTypes(ctx context.Context, s *transport.Session, index, field string) ([]string, error) {
+	resp := fieldCapsResponse{}
+	path := fmt.Sprintf("/%s/_field_caps?fields=%s", index, url.QueryEscape(field))
+	if err := doJSON(ctx, s, "GET", path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get field capabilities: %w", err)
+	}
+
+	types := []string{}
+	for t := range resp.Fields[field] {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return types, nil
+}
+
+func parseFieldCaps(resp *fieldCapsResponse) []*client.FieldInfo {
+	result := make([]*client.FieldInfo, 0, len(resp.Fields))
+	for name, types := range resp.Fields {
+		// metadata fields like _id or _index are not interesting for filtering
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		fi := &client.FieldInfo{
+			Name:         name,
+			Searchable:   true,
+			Aggregatable: true,
+		}
+
+		for t, caps := range types {
+			// object fields are only containers for their subfields
+			if t == "object" || t == "nested" {
+				continue
+			}
+
+			fi.Types = append(fi.Types, t)
+			fi.Searchable = fi.Searchable && caps.Searchable
+			fi.Aggregatable = fi.Aggregatable && caps.Aggregatable
+
+			if len(caps.Indices) > 0 {
+				if fi.Indices == nil {
+					fi.Indices = map[string][]string{}
+				}
+				fi.Indices[t] = caps.Indices
+			}
+		}
+
+		if len(fi.Types) == 0 {
+			continue
+		}
+
+		sort.Strings(fi.Types)
+		result = append(result, fi)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
@@ -0,0 +1,54 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"elastiq/client"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseFieldCaps(t *testing.T) {
+	raw := `{
+		"indices": ["logs-1", "logs-2"],
+		"fields": {
+			"_id": {"_id": {"type": "_id", "searchable": true, "aggregatable": false}},
+			"kubernetes": {"object": {"type": "object", "searchable": false, "aggregatable": false}},
+			"kubernetes.pod.name": {"keyword": {"type": "keyword", "searchable": true, "aggregatable": true}},
+			"http.status_code": {
+				"long": {"type": "long", "searchable": true, "aggregatable": true, "indices": ["logs-1"]},
+				"keyword": {"type": "keyword", "searchable": true, "aggregatable": true, "indices": ["logs-2"]}
+			},
+			"message": {"text": {"type": "text", "searchable": true, "aggregatable": false}}
+		}
+	}`
+
+	resp := fieldCapsResponse{}
+	require.NoError(t, json.Unmarshal([]byte(raw), &resp))
+
+	require.Equal(t, []*client.FieldInfo{
+		{
+			Name:         "http.status_code",
+			Types:        []string{"keyword", "long"},
+			Searchable:   true,
+			Aggregatable: true,
+			Indices: map[string][]string{
+				"long":    {"logs-1"},
+				"keyword": {"logs-2"},
+			},
+		},
+		{
+			Name:         "kubernetes.pod.name",
+			Types:        []string{"keyword"},
+			Searchable:   true,
+			Aggregatable: true,
+		},
+		{
+			Name:         "message",
+			Types:        []string{"text"},
+			Searchable:   true,
+			Aggregatable: false,
+		},
+	}, parseFieldCaps(&resp))
+}
@@ -0,0 +1,233 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_composeIDsRequest(t *testing.T) {
+	body, err := composeIDsRequest(&query.Query{}, "a", "b")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"size": 10, "query": {"ids": {"values": ["a", "b"]}}}`, string(body))
+
+	body, err = composeIDsRequest(&query.Query{
+		Limit:          1,
+		Includes:       []string{"message"},
+		Excludes:       []string{"payload"},
+		Fields:         []string{"host"},
+		DocValueFields: []string{"@timestamp"},
+	}, "a")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"size": 1,
+		"query": {"ids": {"values": ["a"]}},
+		"_source": {"includes": ["message"], "excludes": ["payload"]},
+		"fields": ["host"],
+		"docvalue_fields": ["@timestamp"]
+	}`, string(body))
+}
+
+func Test_parseDocResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  bool
+		hits int
+	}{
+		{
+			name: "found document",
+			body: `{"_index": "logs", "_id": "a", "found": true, "_source": {"message": "hi"}}`,
+			hits: 1,
+		},
+		{
+			name: "missing document",
+			body: `{"_index": "logs", "_id": "a", "found": false}`,
+		},
+		{
+			name: "invalid json",
+			body: `{"_index"`,
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseDocResponse(strings.NewReader(tt.body))
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, resp.Hits.Hits, tt.hits)
+		})
+	}
+}
+
+// getServer returns server replying to _doc API and ids search with the doc of id "a",
+// requests are recorded as "method path?query body"
+func getServer(t *testing.T) (*httptest.Server, *[]string) {
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body)))
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/logs/_doc/a":
+			fmt.Fprint(w, `{"_index": "logs", "_id": "a", "found": true, "_source": {"message": "hi", "payload": "big"}}`)
+
+		case r.URL.Path == "/broken/_doc/a":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error": "oops"}`)
+
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/_search"):
+			hits := `[]`
+			if strings.Contains(string(body), `"values":["a"]`) {
+				hits = `[{"_index": "logs-2", "_id": "a", "_source": {"message": "hi"}}]`
+			}
+			fmt.Fprintf(w, `{"hits": {"hits": %s}}`, hits)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"_index": "logs", "found": false}`)
+		}
+	}))
+
+	return srv, &requests
+}
+
+func TestGet(t *testing.T) {
+	srv, requests := getServer(t)
+	defer srv.Close()
+
+	cfg, e := fakeEnv(t, srv, `
+[output.short]
+only   = ["message"]
+format = "json"
+`)
+	c := NewClient(cfg).(*elasticlient)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		index   string
+		id      string
+		output  string
+		err     string
+		records []map[string]interface{}
+		request string
+	}{
+		{
+			name:    "document of concrete index",
+			id:      "a",
+			records: []map[string]interface{}{{"message": "hi", "payload": "big"}},
+			request: "GET /logs/_doc/a",
+		},
+		{
+			name:    "source filtered by output",
+			id:      "a",
+			output:  "short",
+			records: []map[string]interface{}{{"message": "hi"}},
+			request: "GET /logs/_doc/a?_source_includes=message",
+		},
+		{
+			name:    "missing document",
+			id:      "b",
+			err:     "document with id='b' not found in index='logs'",
+			request: "GET /logs/_doc/b",
+		},
+		{
+			name:    "id is escaped",
+			id:      "a/b",
+			err:     "document with id='a/b' not found in index='logs'",
+			request: "GET /logs/_doc/a%2Fb",
+		},
+		{
+			name:    "unexpected code",
+			index:   "broken",
+			id:      "a",
+			err:     `got unexpected http code=500, body='{"error": "oops"}'`,
+			request: "GET /broken/_doc/a",
+		},
+		{
+			name:    "pattern is searched by ids",
+			index:   "logs-*",
+			id:      "a",
+			records: []map[string]interface{}{{"message": "hi"}},
+			request: `POST /logs-*/_search {"size":10,"query":{"ids":{"values":["a"]}}}`,
+		},
+		{
+			name:    "list of indices is searched by ids",
+			index:   "logs-1,logs-3",
+			id:      "a",
+			output:  "short",
+			records: []map[string]interface{}{{"message": "hi"}},
+			request: `POST /logs-1,logs-3/_search {"size":10,"query":{"ids":{"values":["a"]}},"_source":{"includes":["message"]}}`,
+		},
+		{
+			name:    "missing document of pattern",
+			index:   "logs-*",
+			id:      "b",
+			err:     "document with id='b' not found in index='logs-*'",
+			request: `POST /logs-*/_search {"size":10,"query":{"ids":{"values":["b"]}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*requests = nil
+			r, err := c.Get(ctx, e, tt.id, &query.Query{Index: tt.index, Output: tt.output}, query.Options{})
+			require.Equal(t, []string{tt.request}, *requests)
+
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.records, decodeRecords(t, r))
+		})
+	}
+
+	// raw document is shown as elasticsearch returns it, without source filtering
+	t.Run("raw document", func(t *testing.T) {
+		*requests = nil
+		r, err := c.Get(ctx, e, "a", &query.Query{Output: "short"}, query.Options{Raw: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"GET /logs/_doc/a"}, *requests)
+
+		doc := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r).Decode(&doc))
+		require.Equal(t, map[string]interface{}{"message": "hi", "payload": "big"}, doc["_source"])
+	})
+
+	t.Run("as curl", func(t *testing.T) {
+		*requests = nil
+		r, err := c.Get(ctx, e, "a", &query.Query{Index: "logs-*"}, query.Options{AsCurl: true})
+		require.NoError(t, err)
+		require.Empty(t, *requests)
+
+		curl, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Contains(t, string(curl), srv.URL+"/logs-*/_search")
+		require.Contains(t, string(curl), `{"size":10,"query":{"ids":{"values":["a"]}}}`)
+	})
+
+	t.Run("no index", func(t *testing.T) {
+		e.Index = ""
+		defer func() { e.Index = "logs" }()
+
+		_, err := c.Get(ctx, e, "a", &query.Query{}, query.Options{})
+		require.EqualError(t, err, "neither index was specified, nor default index for env was found")
+	})
+}
@@ -0,0 +1,150 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"elastiq/client"
+	"elastiq/config"
+)
+
+const (
+	IndexTypeIndex      = "index"
+	IndexTypeAlias      = "alias"
+	IndexTypeDataStream = "data_stream"
+)
+
+type catIndex struct {
+	Index   string  `json:"index"`
+	Health  string  `json:"health"`
+	Status  string  `json:"status"`
+	Docs    *string `json:"docs.count"`
+	Size    *string `json:"store.size"`
+	Created string  `json:"creation.date.string"`
+}
+
+type resolveResponse struct {
+	Aliases []struct {
+		Name    string   `json:"name"`
+		Indices []string `json:"indices"`
+	} `json:"aliases"`
+	DataStreams []struct {
+		Name           string   `json:"name"`
+		BackingIndices []string `json:"backing_indices"`
+	} `json:"data_streams"`
+}
+
+func parseCount(str *string) int64 {
+	if str == nil {
+		return 0
+	}
+
+	v, _ := strconv.ParseInt(*str, 10, 64)
+	return v
+}
+
+func (c *elasticlient) Indices(ctx context.Context, e *config.Env, pattern string) ([]*client.IndexInfo, error) {
+	if pattern == "" {
+		pattern = e.Index
+	}
+
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	s := newSession(ctx, e)
+	cat := []catIndex{}
+	path := fmt.Sprintf(
+		"/_cat/indices/%s?format=json&bytes=b&h=index,health,status,docs.count,store.size,creation.date.string",
+		pattern,
+	)
+
+	if err := doJSON(ctx, s, "GET", path, nil, &cat); err != nil {
+		return nil, fmt.Errorf("failed to list indices: %w", err)
+	}
+
+	result := make([]*client.IndexInfo, 0, len(cat))
+	indices := make(map[string]*client.IndexInfo, len(cat))
+	for _, v := range cat {
+		info := &client.IndexInfo{
+			Name:    v.Index,
+			Type:    IndexTypeIndex,
+			Health:  v.Health,
+			Status:  v.Status,
+			Docs:    parseCount(v.Docs),
+			Size:    parseCount(v.Size),
+			Created: v.Created,
+		}
+
+		indices[v.Index] = info
+		result = append(result, info)
+	}
+
+	resolved := resolveResponse{}
+	path = fmt.Sprintf("/_resolve/index/%s", pattern)
+	if err := doJSON(ctx, s, "GET", path, nil, &resolved); err != nil {
+		// _resolve/index appeared in 7.9, older clusters reject it and will only show plain indices
+		var se *statusError
+		if !errors.As(err, &se) || se.Code != http.StatusBadRequest && se.Code != http.StatusNotFound {
+			fmt.Fprintf(os.Stderr, "warning: failed to resolve aliases and data streams, only indices are shown: %s\n", err)
+		}
+
+		return result, nil
+	}
+
+	for _, v := range resolved.Aliases {
+		result = append(result, aggregateIndices(v.Name, IndexTypeAlias, v.Indices, indices))
+	}
+
+	for _, v := range resolved.DataStreams {
+		result = append(result, aggregateIndices(v.Name, IndexTypeDataStream, v.BackingIndices, indices))
+	}
+
+	return result, nil
+}
+
+func aggregateIndices(name, t string, names []string, indices map[string]*client.IndexInfo) *client.IndexInfo {
+	info := &client.IndexInfo{
+		Name:    name,
+		Type:    t,
+		Indices: names,
+	}
+
+	for _, n := range names {
+		index, ok := indices[n]
+		if !ok {
+			continue
+		}
+
+		info.Docs += index.Docs
+		info.Size += index.Size
+
+		if info.Created == "" || index.Created < info.Created {
+			info.Created = index.Created
+		}
+
+		// the worst health of underlying indices is the health of the whole alias
+		if healthRank(index.Health) > healthRank(info.Health) {
+			info.Health = index.Health
+		}
+	}
+
+	return info
+}
+
+func healthRank(health string) int {
+	switch health {
+	case "green":
+		return 1
+	case "yellow":
+		return 2
+	case "red":
+		return 3
+	}
+
+	return 0
+}
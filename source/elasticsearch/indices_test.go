@@ -0,0 +1,106 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"elastiq/client"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthRank(t *testing.T) {
+	require.Less(t, healthRank(""), healthRank("green"))
+	require.Less(t, healthRank("green"), healthRank("yellow"))
+	require.Less(t, healthRank("yellow"), healthRank("red"))
+	require.Equal(t, 0, healthRank("unknown"))
+}
+
+func TestAggregateIndices(t *testing.T) {
+	indices := map[string]*client.IndexInfo{
+		"logs-1": {Name: "logs-1", Health: "green", Docs: 10, Size: 100, Created: "2021-07-02T00:00:00.000Z"},
+		"logs-2": {Name: "logs-2", Health: "yellow", Docs: 20, Size: 200, Created: "2021-07-01T00:00:00.000Z"},
+		"logs-3": {Name: "logs-3", Health: "green", Docs: 30, Size: 300, Created: "2021-07-03T00:00:00.000Z"},
+	}
+
+	tests := []struct {
+		name   string
+		names  []string
+		result client.IndexInfo
+	}{
+		{
+			name:  "the worst health and the earliest creation",
+			names: []string{"logs-1", "logs-2", "logs-3"},
+			result: client.IndexInfo{
+				Name: "logs", Type: IndexTypeAlias, Indices: []string{"logs-1", "logs-2", "logs-3"},
+				Health: "yellow", Docs: 60, Size: 600, Created: "2021-07-01T00:00:00.000Z",
+			},
+		},
+		{
+			name:  "indices not matched by pattern are skipped",
+			names: []string{"logs-3", "other"},
+			result: client.IndexInfo{
+				Name: "logs", Type: IndexTypeAlias, Indices: []string{"logs-3", "other"},
+				Health: "green", Docs: 30, Size: 300, Created: "2021-07-03T00:00:00.000Z",
+			},
+		},
+		{
+			name:   "no known indices",
+			names:  []string{"other"},
+			result: client.IndexInfo{Name: "logs", Type: IndexTypeAlias, Indices: []string{"other"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.result, *aggregateIndices("logs", IndexTypeAlias, tt.names, indices))
+		})
+	}
+}
+
+func TestIndices(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		resolve string
+		names   []string
+	}{
+		{
+			name:    "aliases and data streams",
+			status:  http.StatusOK,
+			resolve: `{"aliases": [{"name": "logs", "indices": ["logs-1"]}], "data_streams": [{"name": "events", "backing_indices": ["logs-1"]}]}`,
+			names:   []string{"logs-1", "logs", "events"},
+		},
+		{name: "cluster without resolve", status: http.StatusBadRequest, names: []string{"logs-1"}},
+		{name: "failed resolve", status: http.StatusForbidden, names: []string{"logs-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/_resolve/index/") {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.resolve))
+					return
+				}
+
+				require.NoError(t, json.NewEncoder(w).Encode([]map[string]string{{"index": "logs-1", "health": "green", "docs.count": "10"}}))
+			}))
+			defer srv.Close()
+
+			cfg, e := fakeEnv(t, srv)
+			indices, err := NewClient(cfg).(*elasticlient).Indices(context.Background(), e, "logs*")
+			require.NoError(t, err)
+
+			names := []string{}
+			for _, i := range indices {
+				names = append(names, i.Name)
+			}
+			require.Equal(t, tt.names, names)
+		})
+	}
+}
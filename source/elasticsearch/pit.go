@@ -0,0 +1,108 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"elastiq/transport"
+)
+
+// keepAlive is how long point in time and scroll are kept between pages,
+// export keeps them longer, since pages wait for slower writing of records
+const (
+	keepAlive       = "1m"
+	exportKeepAlive = "5m"
+)
+
+var errPITUnsupported = errors.New("point in time is not supported by cluster or not allowed for the user")
+
+// openPIT opens point in time for index, errPITUnsupported is returned for clusters without point in time API (before 7.10)
+// and for users without open_point_in_time privilege, who still can page with scroll
+func openPIT(ctx context.Context, s *transport.Session, index, keepAlive string) (*PIT, error) {
+	path := fmt.Sprintf("/%s/_pit?keep_alive=%s", index, keepAlive)
+	req, err := newRequest(ctx, s, "POST", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	j, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read all: %w", err)
+	}
+
+	switch res.StatusCode {
+	case 200:
+	case 400, 403, 404, 405:
+		return nil, errPITUnsupported
+	default:
+		return nil, fmt.Errorf("failed to open point in time, got unexpected http code=%d, body='%s'", res.StatusCode, string(j))
+	}
+
+	pit := PIT{}
+	if err := json.Unmarshal(j, &pit); err != nil {
+		return nil, fmt.Errorf("failed to parse point in time: %w", err)
+	}
+
+	pit.KeepAlive = keepAlive
+	return &pit, nil
+}
+
+// searchPIT runs a search in point in time composed by compose, pit id is updated since it may change between searches.
+// Clusters before 7.12 open point in time, but can't sort by _shard_doc,
+// so the search is repeated with _id tiebreaker, which pit keeps for the next searches
+func searchPIT(ctx context.Context, s *transport.Session, pit *PIT, compose func() ([]byte, error)) (*response, error) {
+	for {
+		body, err := compose()
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose request: %w", err)
+		}
+
+		// search with point in time is run without index
+		resp := &response{}
+		err = doJSON(ctx, s, "POST", "/_search", body, resp)
+
+		var se *statusError
+		if pit.Tiebreaker == "" && errors.As(err, &se) && se.Code == http.StatusBadRequest && strings.Contains(se.Body, "_shard_doc") {
+			pit.Tiebreaker = "_id"
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.PITID != "" {
+			pit.ID = resp.PITID
+		}
+
+		return resp, nil
+	}
+}
+
+// closePIT releases point in time, failure is only reported since it expires anyway
+func closePIT(ctx context.Context, s *transport.Session, pit *PIT) {
+	body, _ := json.Marshal(map[string]string{"id": pit.ID})
+	if err := doJSON(ctx, s, "DELETE", "/_pit", body, &map[string]interface{}{}); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to close point in time: %s\n", err)
+	}
+}
+
+// clearScroll releases scroll context, failure is only reported since it expires anyway
+func clearScroll(ctx context.Context, s *transport.Session, id string) {
+	body, _ := json.Marshal(map[string]interface{}{"scroll_id": []string{id}})
+	if err := doJSON(ctx, s, "DELETE", "/_search/scroll", body, &map[string]interface{}{}); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to clear scroll: %s\n", err)
+	}
+}
@@ -7,7 +7,10 @@ import (
 )
 
 type RawOrder struct {
-	Order string `json:"order"`
+	Order        string `json:"order"`
+	Missing      string `json:"missing,omitempty"`
+	UnmappedType string `json:"unmapped_type,omitempty"`
+	NumericType  string `json:"numeric_type,omitempty"`
 }
 
 type RawFilter struct {
@@ -21,20 +24,65 @@ type RawQuery struct {
 	Bool RawFilter `json:"bool"`
 }
 
+// PIT is a point in time paged search is run against, so pages are consistent while indices refresh
+type PIT struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive"`
+
+	// Tiebreaker is the last sort key of searches in point in time, _shard_doc if empty
+	Tiebreaker string `json:"-"`
+}
+
+// RawSlice splits search into max slices to be searched concurrently,
+// records are distributed among slices by field (by _id if not specified)
+type RawSlice struct {
+	ID    int    `json:"id"`
+	Max   int    `json:"max"`
+	Field string `json:"field,omitempty"`
+}
+
+// RawSource limits fields of _source returned by elasticsearch
+type RawSource struct {
+	Includes []string `json:"includes,omitempty"`
+	Excludes []string `json:"excludes,omitempty"`
+}
+
 type ElasticRequest struct {
-	Limit     int                   `json:"size"`
-	StartFrom query.StartFrom       `json:"search_after,omitempty"`
-	Sort      []map[string]RawOrder `json:"sort"`
-	Query     RawQuery              `json:"query"`
+	Limit          int                   `json:"size"`
+	StartFrom      query.StartFrom       `json:"search_after,omitempty"`
+	Sort           []map[string]RawOrder `json:"sort"`
+	Query          RawQuery              `json:"query"`
+	PIT            *PIT                  `json:"pit,omitempty"`
+	Slice          *RawSlice             `json:"slice,omitempty"`
+	TrackTotalHits bool                  `json:"track_total_hits,omitempty"`
+	Source         *RawSource            `json:"_source,omitempty"`
+	Fields         []string              `json:"fields,omitempty"`
+	DocValueFields []string              `json:"docvalue_fields,omitempty"`
 }
 
 func ComposeRequest(q *query.Query, sf query.StartFrom) ([]byte, error) {
-	order := q.Order
-	if order == nil {
-		order = &query.Order{
+	return composeRequest(q, sf, nil, nil)
+}
+
+// ComposePITRequest composes request of a page searched in point in time,
+// tiebreaker of pit (_shard_doc by default) is added to sort, so records with equal sort values are never skipped or repeated
+func ComposePITRequest(q *query.Query, sf query.StartFrom, pit *PIT) ([]byte, error) {
+	return composeRequest(q, sf, pit, nil)
+}
+
+// ComposeSliceRequest composes request of a page of slice searched either in point in time or with scroll (pit is nil),
+// total hits of the slice are counted
+func ComposeSliceRequest(q *query.Query, sf query.StartFrom, pit *PIT, slice *RawSlice) ([]byte, error) {
+	return composeRequest(q, sf, pit, slice)
+}
+
+func composeRequest(q *query.Query, sf query.StartFrom, pit *PIT, slice *RawSlice) ([]byte, error) {
+	orders := q.Order
+	if len(orders) == 0 {
+		orders = []*query.Order{{
 			By:        "@timestamp",
 			Ascending: false,
-		}
+		}}
 	}
 
 	limit := q.Limit
@@ -42,22 +90,57 @@ func ComposeRequest(q *query.Query, sf query.StartFrom) ([]byte, error) {
 		limit = 10
 	}
 
-	o := RawOrder{
-		Order: "desc",
+	elkr := ElasticRequest{
+		Limit:     limit,
+		StartFrom: sf,
+		Sort:      make([]map[string]RawOrder, 0, len(orders)+1),
+	}
+
+	for _, order := range orders {
+		o := RawOrder{
+			Order:        "desc",
+			Missing:      order.Missing,
+			UnmappedType: order.UnmappedType,
+			NumericType:  order.NumericType,
+		}
+
+		if order.Ascending {
+			o.Order = "asc"
+		}
+
+		elkr.Sort = append(elkr.Sort, map[string]RawOrder{order.By: o})
 	}
 
-	if order.Ascending {
-		o.Order = "asc"
+	// tiebreaker follows direction of the last key, so search after a record continues in the same order
+	if pit != nil {
+		elkr.PIT = pit
+		tiebreaker := RawOrder{Order: "desc"}
+		if orders[len(orders)-1].Ascending {
+			tiebreaker.Order = "asc"
+		}
+
+		by := pit.Tiebreaker
+		if by == "" {
+			by = "_shard_doc"
+		}
+
+		elkr.Sort = append(elkr.Sort, map[string]RawOrder{by: tiebreaker})
 	}
 
-	elkr := ElasticRequest{
-		Limit:     limit,
-		StartFrom: sf,
-		Sort: []map[string]RawOrder{
-			{
-				order.By: o,
-			},
-		},
+	if len(q.Includes) > 0 || len(q.Excludes) > 0 {
+		elkr.Source = &RawSource{Includes: q.Includes, Excludes: q.Excludes}
+	}
+
+	elkr.Fields = q.Fields
+	elkr.DocValueFields = q.DocValueFields
+
+	if slice != nil {
+		elkr.TrackTotalHits = true
+
+		// a single slice is the whole search
+		if slice.Max > 1 {
+			elkr.Slice = slice
+		}
 	}
 
 	rfs := []interface{}{}
@@ -75,7 +158,7 @@ func ComposeRequest(q *query.Query, sf query.StartFrom) ([]byte, error) {
 			shouldsCnt++
 			shoulds = append(shoulds, rf...)
 
-		case query.NEQ:
+		case query.NEQ, query.NEX:
 			mustnots = append(mustnots, rf...)
 
 		default:
@@ -102,12 +185,18 @@ func ComposeRequest(q *query.Query, sf query.StartFrom) ([]byte, error) {
 	return j, nil
 }
 
-func rangeStatement(op, key, value string) map[string]interface{} {
+func rangeStatement(op, key, value, format string) map[string]interface{} {
+	cond := map[string]string{
+		op: value,
+	}
+
+	if format != "" {
+		cond["format"] = format
+	}
+
 	return map[string]interface{}{
 		"range": map[string]interface{}{
-			key: map[string]string{
-				op: value,
-			},
+			key: cond,
 		},
 	}
 }
@@ -140,24 +229,30 @@ func ComposeFilter(f *query.Filter) ([]interface{}, error) {
 		}
 
 	case query.GT:
-		res = rangeStatement("gt", f.Key, f.Value[0])
+		res = rangeStatement("gt", f.Key, f.Value[0], f.Format)
 
 	case query.GTE:
-		res = rangeStatement("gte", f.Key, f.Value[0])
+		res = rangeStatement("gte", f.Key, f.Value[0], f.Format)
 
 	case query.LT:
-		res = rangeStatement("lt", f.Key, f.Value[0])
+		res = rangeStatement("lt", f.Key, f.Value[0], f.Format)
 
 	case query.LTE:
-		res = rangeStatement("lte", f.Key, f.Value[0])
+		res = rangeStatement("lte", f.Key, f.Value[0], f.Format)
 
 	case query.BT, query.BTT:
+		cond := map[string]string{
+			"gte": f.Value[0],
+			"lte": f.Value[1],
+		}
+
+		if f.Format != "" {
+			cond["format"] = f.Format
+		}
+
 		res = map[string]interface{}{
 			"range": map[string]interface{}{
-				f.Key: map[string]string{
-					"gte": f.Value[0],
-					"lte": f.Value[1],
-				},
+				f.Key: cond,
 			},
 		}
 
@@ -180,7 +275,7 @@ func ComposeFilter(f *query.Filter) ([]interface{}, error) {
 			},
 		}
 
-	case query.EX:
+	case query.EX, query.NEX:
 		res = map[string]interface{}{
 			"exists": map[string]string{
 				"field": f.Key,
@@ -194,3 +289,35 @@ func ComposeFilter(f *query.Filter) ([]interface{}, error) {
 
 	return nil, fmt.Errorf("unknown operation='%s'", f.Operation)
 }
+
+type IDsRequest struct {
+	Limit int `json:"size"`
+	Query struct {
+		IDs struct {
+			Values []string `json:"values"`
+		} `json:"ids"`
+	} `json:"query"`
+	Source         *RawSource `json:"_source,omitempty"`
+	Fields         []string   `json:"fields,omitempty"`
+	DocValueFields []string   `json:"docvalue_fields,omitempty"`
+}
+
+func composeIDsRequest(q *query.Query, ids ...string) ([]byte, error) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = 10
+	}
+
+	r := IDsRequest{Limit: limit, Fields: q.Fields, DocValueFields: q.DocValueFields}
+	r.Query.IDs.Values = ids
+	if len(q.Includes) > 0 || len(q.Excludes) > 0 {
+		r.Source = &RawSource{Includes: q.Includes, Excludes: q.Excludes}
+	}
+
+	j, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return j, nil
+}
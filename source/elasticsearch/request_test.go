@@ -209,3 +209,80 @@ func TestComposeFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestComposePITRequest(t *testing.T) {
+	q := &query.Query{
+		Limit: 100,
+		Order: []*query.Order{{By: "@timestamp", Ascending: true}},
+	}
+
+	body, err := elasticsearch.ComposePITRequest(q, &[]interface{}{"1626256801000", "42"}, &elasticsearch.PIT{ID: "pit-id", KeepAlive: "1m"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"size": 100,
+		"search_after": ["1626256801000", "42"],
+		"sort": [{"@timestamp": {"order": "asc"}}, {"_shard_doc": {"order": "asc"}}],
+		"query": {"bool": {"filter": [], "should": [], "must_not": [], "minimum_should_match": 0}},
+		"pit": {"id": "pit-id", "keep_alive": "1m"}
+	}`, string(body))
+
+	body, err = elasticsearch.ComposeRequest(q, nil)
+	require.NoError(t, err)
+	require.NotContains(t, string(body), "_shard_doc")
+	require.NotContains(t, string(body), "pit")
+}
+
+func TestComposeSliceRequest(t *testing.T) {
+	q := &query.Query{
+		Limit: 1000,
+		Order: []*query.Order{{By: "@timestamp", Ascending: true}},
+	}
+
+	body, err := elasticsearch.ComposeSliceRequest(q, nil, nil, &elasticsearch.RawSlice{ID: 1, Max: 4})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"size": 1000,
+		"sort": [{"@timestamp": {"order": "asc"}}],
+		"query": {"bool": {"filter": [], "should": [], "must_not": [], "minimum_should_match": 0}},
+		"slice": {"id": 1, "max": 4},
+		"track_total_hits": true
+	}`, string(body))
+
+	// a single slice is the whole search
+	body, err = elasticsearch.ComposeSliceRequest(q, nil, nil, &elasticsearch.RawSlice{ID: 0, Max: 1})
+	require.NoError(t, err)
+	require.NotContains(t, string(body), "slice")
+
+	// records without the timestamp sorted as date_nanos across indices
+	q = &query.Query{
+		Limit:   1000,
+		Order:   []*query.Order{{By: "@timestamp", Ascending: true, Missing: "_last", NumericType: "date_nanos"}},
+		Filters: []*query.Filter{{Key: "@timestamp", Operation: query.NEX}},
+	}
+
+	body, err = elasticsearch.ComposeSliceRequest(q, nil, nil, &elasticsearch.RawSlice{ID: 0, Max: 1})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"size": 1000,
+		"sort": [{"@timestamp": {"order": "asc", "missing": "_last", "numeric_type": "date_nanos"}}],
+		"query": {"bool": {"filter": [], "should": [], "must_not": [{"exists": {"field": "@timestamp"}}], "minimum_should_match": 0}},
+		"track_total_hits": true
+	}`, string(body))
+}
+
+func TestComposeRequestSort(t *testing.T) {
+	orders, err := query.GetOrders("level/asc/first/unmapped=keyword,@timestamp/desc")
+	require.NoError(t, err)
+
+	body, err := elasticsearch.ComposeRequest(&query.Query{Limit: 10, Order: orders}, &[]interface{}{"error", "1626256801000"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"size": 10,
+		"search_after": ["error", "1626256801000"],
+		"sort": [
+			{"level": {"order": "asc", "missing": "_first", "unmapped_type": "keyword"}},
+			{"@timestamp": {"order": "desc"}}
+		],
+		"query": {"bool": {"filter": [], "should": [], "must_not": [], "minimum_should_match": 0}}
+	}`, string(body))
+}
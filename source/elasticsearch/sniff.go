@@ -0,0 +1,72 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"elastiq/config"
+	"elastiq/transport"
+)
+
+type nodesResponse struct {
+	Nodes map[string]struct {
+		HTTP struct {
+			PublishAddress string `json:"publish_address"`
+		} `json:"http"`
+	} `json:"nodes"`
+}
+
+// newSession creates a session sticking to a single node,
+// nodes of the cluster are sniffed before the first session if env has sniff enabled
+func newSession(ctx context.Context, e *config.Env) *transport.Session {
+	if e.Sniff != nil && *e.Sniff {
+		err := transport.SniffOnce(e, func(s *transport.Session) ([]string, error) {
+			return sniff(ctx, s)
+		})
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to sniff cluster nodes, using configured endpoints: %s\n", err)
+		}
+	}
+
+	return transport.NewSession(e)
+}
+
+func sniff(ctx context.Context, s *transport.Session) ([]string, error) {
+	u, err := url.Parse(s.Endpoint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint='%s': %w", s.Endpoint(), err)
+	}
+
+	// nodes publish their own addresses, which have neither the path of a proxy nor the proxy in front of them
+	if strings.Trim(u.Path, "/") != "" {
+		return nil, fmt.Errorf("endpoint='%s' has path prefix, nodes behind it can't be requested directly", s.Endpoint())
+	}
+
+	resp := nodesResponse{}
+	if err := doJSON(ctx, s, "GET", "/_nodes/http", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	endpoints := []string{}
+	for _, v := range resp.Nodes {
+		addr := v.HTTP.PublishAddress
+		if addr == "" {
+			continue
+		}
+
+		// publish address may look like "hostname/10.0.0.1:9200"
+		if i := strings.LastIndex(addr, "/"); i >= 0 {
+			addr = addr[i+1:]
+		}
+
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s", u.Scheme, addr))
+	}
+
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
@@ -0,0 +1,66 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"elastiq/config"
+	"elastiq/transport"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniff(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.Equal(t, "/_nodes/http", r.URL.Path)
+		fmt.Fprint(w, `{"nodes": {
+			"a": {"http": {"publish_address": "es-1/10.0.0.1:9200"}},
+			"b": {"http": {"publish_address": "10.0.0.2:9200"}},
+			"c": {"http": {}}
+		}}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		endpoint  string
+		endpoints []string
+		err       string
+		requests  int
+	}{
+		{
+			name:      "nodes of cluster",
+			endpoint:  srv.URL,
+			endpoints: []string{"http://10.0.0.1:9200", "http://10.0.0.2:9200"},
+			requests:  1,
+		},
+		{
+			name:     "endpoint with path prefix",
+			endpoint: srv.URL + "/es",
+			err:      fmt.Sprintf("endpoint='%s/es' has path prefix, nodes behind it can't be requested directly", srv.URL),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			e := &config.Env{Endpoints: []string{tt.endpoint}}
+
+			endpoints, err := sniff(context.Background(), transport.NewSession(e))
+			require.Equal(t, tt.requests, requests)
+
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.endpoints, endpoints)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"elastiq/config"
+	"elastiq/query"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_filterSource(t *testing.T) {
+	q := &query.Query{Limit: 10}
+
+	tests := []struct {
+		name     string
+		output   *config.Output
+		keep     []string
+		includes []string
+		excludes []string
+	}{
+		{
+			name:   "whole records",
+			output: &config.Output{},
+		},
+		{
+			name:     "only with kept fields",
+			output:   &config.Output{Only: []string{"message"}, Exclude: []string{"payload"}},
+			keep:     []string{"@timestamp"},
+			includes: []string{"message", "@timestamp"},
+		},
+		{
+			name:     "exclude of kept fields",
+			output:   &config.Output{Exclude: []string{"payload", "@timestamp", "kubernetes"}},
+			keep:     []string{"@timestamp", "kubernetes.labels.app"},
+			excludes: []string{"payload"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qq := filterSource(q, tt.output, tt.keep...)
+			require.Equal(t, tt.includes, qq.Includes)
+			require.Equal(t, tt.excludes, qq.Excludes)
+			require.Nil(t, q.Includes)
+		})
+	}
+}
+
+func Test_unwrapSource(t *testing.T) {
+	h := hit{}
+	err := json.Unmarshal([]byte(`{
+		"_source": {"message": "hi", "kubernetes": {"pod": {"name": "pod-1"}}},
+		"fields": {"kubernetes.pod.name": ["pod-2"], "duration_ms": [12], "tags": ["a", "b"]}
+	}`), &h)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]interface{}{
+		"message":     "hi",
+		"kubernetes":  map[string]interface{}{"pod": map[string]interface{}{"name": "pod-1"}},
+		"duration_ms": "12",
+		"tags":        []interface{}{"a", "b"},
+	}, unwrapSource(&h))
+}
+
+func Test_docSourceParams(t *testing.T) {
+	require.Equal(t, "", docSourceParams(&query.Query{}))
+	require.Equal(t, "?_source_excludes=payload&_source_includes=message%2C%40timestamp", docSourceParams(&query.Query{
+		Includes: []string{"message", "@timestamp"},
+		Excludes: []string{"payload"},
+	}))
+}
+
+func TestQuerySourceFiltering(t *testing.T) {
+	fc, srv := newFakeCluster(t, fakeDocs(5))
+	defer srv.Close()
+
+	cfg, e := fakeEnv(t, srv, `
+[output.short]
+only   = ["message"]
+format = "json"
+`)
+	c := NewClient(cfg).(*elasticlient)
+	q := &query.Query{Limit: 5, Output: "short"}
+
+	_, err := c.Query(context.Background(), e, q, query.Options{})
+	require.NoError(t, err)
+
+	// raw response is shown as elasticsearch returns it
+	_, err = c.Query(context.Background(), e, q, query.Options{Raw: true})
+	require.NoError(t, err)
+
+	searches := fc.searches()
+	require.Len(t, searches, 2)
+	require.Equal(t, map[string]interface{}{"includes": []interface{}{"message"}}, searches[0]["_source"])
+	require.Nil(t, searches[1]["_source"])
+}
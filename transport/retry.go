@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTimeout    = 2 * time.Minute
+	defaultBackoff    = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+type idempotentKey struct{}
+
+// Idempotent marks requests made with the context as safe to be retried
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+
+	v, _ := req.Context().Value(idempotentKey{}).(bool)
+	return v
+}
+
+// retryTransport limits time of every attempt and retries failed idempotent requests
+// with jittered exponential backoff
+type retryTransport struct {
+	next       http.RoundTripper
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func isRetriableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func (rt *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retriable := isIdempotent(req) && (req.Body == nil || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		res, err := rt.attempt(r)
+		if !retriable || attempt >= rt.maxRetries || req.Context().Err() != nil {
+			return res, err
+		}
+
+		if err == nil && !isRetriableStatus(res.StatusCode) {
+			return res, nil
+		}
+
+		wait := rt.delay(attempt)
+		if res != nil {
+			if ra, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+				wait = ra
+				if wait > rt.maxBackoff {
+					wait = rt.maxBackoff
+				}
+			}
+
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// attempt sends the request with timeout, the timeout covers reading of response body as well
+func (rt *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), rt.timeout)
+
+	res, err := rt.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (rt *retryTransport) delay(attempt int) time.Duration {
+	d := rt.backoff << uint(attempt)
+	if d > rt.maxBackoff || d <= 0 {
+		d = rt.maxBackoff
+	}
+
+	// "equal jitter": half of the delay is fixed, the other half is random
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(header); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
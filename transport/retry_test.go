@@ -0,0 +1,121 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"elastiq/config"
+	"elastiq/transport"
+
+	"github.com/stretchr/testify/require"
+)
+
+func retryEnv(url string, retries int) *config.Env {
+	return &config.Env{
+		Endpoints:  []string{url},
+		MaxRetries: &retries,
+		Backoff:    &config.Duration{Duration: time.Millisecond},
+		MaxBackoff: &config.Duration{Duration: 10 * time.Millisecond},
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		retries    int
+		failures   int32
+		status     int
+		idempotent bool
+		attempts   int32
+		code       int
+	}{
+		{name: "success", retries: 3, idempotent: true, attempts: 1, code: 200},
+		{name: "retried", retries: 3, failures: 2, status: 503, idempotent: true, attempts: 3, code: 200},
+		{name: "too many requests", retries: 3, failures: 1, status: 429, idempotent: true, attempts: 2, code: 200},
+		{name: "retries exhausted", retries: 2, failures: 5, status: 502, idempotent: true, attempts: 3, code: 502},
+		{name: "not retriable status", retries: 3, failures: 1, status: 500, idempotent: true, attempts: 1, code: 500},
+		{name: "not idempotent", retries: 3, failures: 1, status: 503, idempotent: false, attempts: 1, code: 503},
+		{name: "retries disabled", retries: 0, failures: 1, status: 503, idempotent: true, attempts: 1, code: 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := int32(0)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				require.Equal(t, "body", string(body))
+
+				if atomic.AddInt32(&attempts, 1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			if tt.idempotent {
+				ctx = transport.Idempotent(ctx)
+			}
+
+			req, err := http.NewRequestWithContext(ctx, "POST", srv.URL, bytes.NewReader([]byte("body")))
+			require.NoError(t, err)
+
+			res, err := transport.NewSession(retryEnv(srv.URL, tt.retries)).Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+
+			require.Equal(t, tt.code, res.StatusCode)
+			require.Equal(t, tt.attempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	attempts := int32(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	e := retryEnv(srv.URL, 1)
+	e.MaxBackoff = &config.Duration{Duration: 2 * time.Second}
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	res, err := transport.NewSession(e).Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	require.Equal(t, 200, res.StatusCode)
+	require.True(t, time.Since(start) >= time.Second)
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	e := retryEnv(srv.URL, 1)
+	e.Timeout = &config.Duration{Duration: 50 * time.Millisecond}
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+
+	_, err = transport.NewSession(e).Do(req)
+	require.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"elastiq/config"
+)
+
+// pool keeps track of endpoints health of an env for the whole run
+type pool struct {
+	mu        sync.Mutex
+	endpoints []string
+	down      map[string]bool
+	sniffOnce sync.Once
+}
+
+var pools = map[*config.Env]*pool{}
+
+func init() {
+	rand.Seed(time.Now().UTC().UnixNano())
+}
+
+func getPool(e *config.Env) *pool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := pools[e]
+	if !ok {
+		p = &pool{
+			endpoints: append([]string{}, e.Endpoints...),
+			down:      map[string]bool{},
+		}
+		pools[e] = p
+	}
+
+	return p
+}
+
+// pick returns random healthy endpoint, if every endpoint is down, a random one is returned with false
+func (p *pool) pick() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	healthy := make([]string, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		if !p.down[ep] {
+			healthy = append(healthy, ep)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return p.endpoints[rand.Intn(len(p.endpoints))], false
+	}
+
+	return healthy[rand.Intn(len(healthy))], true
+}
+
+func (p *pool) markDown(ep string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.down[ep] = true
+}
+
+// SniffOnce replaces endpoints of the env with ones returned by sniff, it is done once per run,
+// sniff failure is not fatal as configured endpoints are still usable
+func SniffOnce(e *config.Env, sniff func(s *Session) ([]string, error)) error {
+	p := getPool(e)
+
+	var err error
+	p.sniffOnce.Do(func() {
+		var endpoints []string
+		endpoints, err = sniff(NewSession(e))
+		if err != nil || len(endpoints) == 0 {
+			return
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.endpoints = endpoints
+	})
+
+	return err
+}
+
+// Session sticks to a single endpoint of the env (e.g. for all pages of a paged query)
+// and fails over to other endpoints when they can't be connected to
+type Session struct {
+	env      *config.Env
+	pool     *pool
+	endpoint string
+}
+
+func NewSession(e *config.Env) *Session {
+	return &Session{env: e, pool: getPool(e)}
+}
+
+func (s *Session) Env() *config.Env {
+	return s.env
+}
+
+// Endpoint returns the endpoint requests of the session should be sent to
+func (s *Session) Endpoint() string {
+	if s.endpoint == "" {
+		// if every endpoint is down, the last chance is to try any of them
+		s.endpoint, _ = s.pool.pick()
+	}
+
+	return s.endpoint
+}
+
+// Do sends the request, which url must start with the session endpoint,
+// on connection failure the endpoint is marked as unhealthy and the request is sent to the next one
+func (s *Session) Do(req *http.Request) (*http.Response, error) {
+	c, err := Client(s.env)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Endpoint()
+
+	authorized := false
+	for {
+		res, err := c.Do(req)
+		if err == nil && res.StatusCode == http.StatusUnauthorized && !authorized && s.env.RefreshAuthorization() {
+			// the token may have expired or been revoked, the request is repeated once with new one
+			authorized = true
+			r, rerr := reauthorize(req, s.env)
+			if rerr != nil {
+				res.Body.Close()
+				return nil, rerr
+			}
+
+			if r == nil {
+				return res, nil
+			}
+
+			res.Body.Close()
+			req = r
+			continue
+		}
+
+		if err == nil || req.Context().Err() != nil || !isConnectionError(err) {
+			return res, err
+		}
+
+		s.pool.markDown(s.endpoint)
+
+		next, ok := s.pool.pick()
+		if !ok || (req.Body != nil && req.GetBody == nil) {
+			return nil, err
+		}
+
+		req, err = rewriteEndpoint(req, s.endpoint, next)
+		if err != nil {
+			return nil, err
+		}
+
+		s.endpoint = next
+	}
+}
+
+// isConnectionError tells whether err means the endpoint can't be connected to,
+// other errors (e.g. TLS failures or timeouts) are not a reason to fail over, since other endpoints would fail the same way
+func isConnectionError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// reauthorize returns copy of req with authorization headers obtained again,
+// nil is returned if the request body can't be sent again
+func reauthorize(req *http.Request, e *config.Env) (*http.Request, error) {
+	if req.Body != nil && req.GetBody == nil {
+		return nil, nil
+	}
+
+	r := req.Clone(req.Context())
+	for k, v := range e.Authorization.Header {
+		value, err := v.GetValue()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get value of header='%s': %w", k, err)
+		}
+
+		r.Header.Set(k, value)
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	return r, nil
+}
+
+func rewriteEndpoint(req *http.Request, from, to string) (*http.Request, error) {
+	u := req.URL.String()
+	if !strings.HasPrefix(u, from) {
+		return nil, fmt.Errorf("request url='%s' does not belong to endpoint='%s'", u, from)
+	}
+
+	nu, err := url.Parse(to + strings.TrimPrefix(u, from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose url for endpoint='%s': %w", to, err)
+	}
+
+	r := req.Clone(req.Context())
+	r.URL = nu
+	r.Host = ""
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	return r, nil
+}
@@ -0,0 +1,177 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"elastiq/cache"
+	"elastiq/config"
+	"elastiq/transport"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionFailover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	e := &config.Env{Endpoints: []string{dead.URL, srv.URL}}
+
+	for i := 0; i < 5; i++ {
+		s := transport.NewSession(e)
+
+		req, err := http.NewRequestWithContext(
+			transport.Idempotent(context.Background()),
+			"POST", s.Endpoint()+"/index/_search", bytes.NewReader([]byte("body")),
+		)
+		require.NoError(t, err)
+
+		res, err := s.Do(req)
+		require.NoError(t, err)
+
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, "body", string(body))
+
+		// dead endpoint is remembered and never picked again
+		require.Equal(t, srv.URL, s.Endpoint())
+	}
+}
+
+func TestSessionNoFailoverOnTLSError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// certificate of the server is not trusted
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer untrusted.Close()
+
+	e := &config.Env{Endpoints: []string{untrusted.URL, srv.URL}}
+
+	failed := 0
+	for i := 0; i < 20; i++ {
+		s := transport.NewSession(e)
+		ep := s.Endpoint()
+
+		req, err := http.NewRequest("GET", ep+"/_nodes", nil)
+		require.NoError(t, err)
+
+		res, err := s.Do(req)
+		if ep == srv.URL {
+			require.NoError(t, err)
+			res.Body.Close()
+			continue
+		}
+
+		failed++
+		require.Error(t, err)
+		require.Equal(t, untrusted.URL, s.Endpoint())
+	}
+
+	// the endpoint is not marked down, so it is picked again
+	require.Greater(t, failed, 1)
+}
+
+func TestSessionAllEndpointsDown(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	e := &config.Env{Endpoints: []string{dead.URL, dead.URL + "/"}}
+	s := transport.NewSession(e)
+
+	req, err := http.NewRequest("GET", s.Endpoint()+"/_nodes", nil)
+	require.NoError(t, err)
+
+	_, err = s.Do(req)
+	require.Error(t, err)
+}
+
+func TestSessionRefreshesAuthorization(t *testing.T) {
+	tokens := 0
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens++
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, tokens)
+	}))
+	defer idp.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first token is revoked
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "elastiq-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv(cache.EnvCacheDir, dir)
+	defer os.Unsetenv(cache.EnvCacheDir)
+
+	cfg := config.Config{}
+	_, err = toml.Decode(fmt.Sprintf(`
+[env.dev]
+endpoints = ["%s"]
+[env.dev.authorization.oauth2]
+token_url     = "%s"
+client_id     = "elastiq"
+client_secret = "secret"
+`, srv.URL, idp.URL), &cfg)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	e, err := cfg.GetEnv("dev")
+	require.NoError(t, err)
+
+	s := transport.NewSession(e)
+	req, err := http.NewRequest("POST", s.Endpoint()+"/index/_search", bytes.NewReader([]byte("body")))
+	require.NoError(t, err)
+
+	header, err := e.Authorization.Header["Authorization"].GetValue()
+	require.NoError(t, err)
+	req.Header.Set("Authorization", header)
+
+	res, err := s.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "body", string(body))
+	require.Equal(t, 2, tokens)
+}
+
+func TestSniffOnce(t *testing.T) {
+	e := &config.Env{Endpoints: []string{"http://localhost:9200"}}
+
+	calls := 0
+	sniff := func(s *transport.Session) ([]string, error) {
+		calls++
+		return []string{"http://10.0.0.1:9200"}, nil
+	}
+
+	require.NoError(t, transport.SniffOnce(e, sniff))
+	require.NoError(t, transport.SniffOnce(e, sniff))
+	require.Equal(t, 1, calls)
+	require.Equal(t, "http://10.0.0.1:9200", transport.NewSession(e).Endpoint())
+}
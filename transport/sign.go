@@ -0,0 +1,26 @@
+package transport
+
+import (
+	"net/http"
+
+	"elastiq/sigv4"
+)
+
+// signingTransport signs every request attempt, so retried and failed over requests get valid signatures
+type signingTransport struct {
+	next   http.RoundTripper
+	signer *sigv4.Signer
+}
+
+func (st *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if err := st.signer.Sign(r); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	return st.next.RoundTrip(r)
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"elastiq/config"
+	"elastiq/sigv4"
+)
+
+var (
+	mu      sync.Mutex
+	clients = map[*config.Env]*http.Client{}
+)
+
+func init() {
+	// token requests of the env go through its transport
+	config.HTTPClient = Client
+}
+
+// Client returns http client configured for the env, it is created once per env and shared by all sources
+func Client(e *config.Env) (*http.Client, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if c, ok := clients[e]; ok {
+		return c, nil
+	}
+
+	c, err := newClient(e)
+	if err != nil {
+		return nil, err
+	}
+
+	clients[e] = c
+	return c, nil
+}
+
+func newClient(e *config.Env) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if e.TLS != nil {
+		tlsConfig, err := newTLSConfig(e.TLS)
+		if err != nil {
+			return nil, err
+		}
+
+		t.TLSClientConfig = tlsConfig
+	}
+
+	if e.Proxy != "" {
+		proxy, err := url.Parse(e.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy='%s': %w", e.Proxy, err)
+		}
+
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	var next http.RoundTripper = t
+	if e.Authorization != nil && e.Authorization.AWS != nil {
+		next = &signingTransport{next: t, signer: sigv4.NewSigner(e.Authorization.AWS)}
+	}
+
+	rt := &retryTransport{
+		next:       next,
+		timeout:    e.Timeout.Or(defaultTimeout),
+		backoff:    e.Backoff.Or(defaultBackoff),
+		maxBackoff: e.MaxBackoff.Or(defaultMaxBackoff),
+	}
+
+	// requests are not retried by default
+	if e.MaxRetries != nil {
+		rt.maxRetries = *e.MaxRetries
+	}
+
+	return &http.Client{Transport: rt}, nil
+}
+
+func newTLSConfig(t *config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		ca, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file='%s': %w", t.CAFile, err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in ca_file='%s'", t.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
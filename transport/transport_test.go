@@ -0,0 +1,65 @@
+package transport_test
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"elastiq/config"
+	"elastiq/transport"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "elastiq-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caFile := path.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caFile, ca, 0600))
+
+	tests := []struct {
+		name string
+		tls  *config.TLS
+		err  bool
+	}{
+		{name: "unknown authority", tls: nil, err: true},
+		{name: "custom ca", tls: &config.TLS{CAFile: caFile}},
+		{name: "insecure", tls: &config.TLS{InsecureSkipVerify: true}},
+		{name: "server name mismatch", tls: &config.TLS{CAFile: caFile, ServerName: "wrong.example.org"}, err: true},
+		{name: "server name override", tls: &config.TLS{CAFile: caFile, ServerName: "example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &config.Env{Endpoints: []string{srv.URL}, TLS: tt.tls}
+
+			req, err := http.NewRequest("GET", srv.URL, nil)
+			require.NoError(t, err)
+
+			res, err := transport.NewSession(e).Do(req)
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				res.Body.Close()
+				require.Equal(t, 200, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestClientTLSInvalidCA(t *testing.T) {
+	e := &config.Env{TLS: &config.TLS{CAFile: "/nonexistent/ca.pem"}}
+	_, err := transport.Client(e)
+	require.Error(t, err)
+}